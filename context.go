@@ -1,6 +1,7 @@
 package pongo2
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -63,9 +64,25 @@ func (c Context) Update(other Context) Context {
 //
 // To create your own execution context within tags, use the
 // NewChildExecutionContext(parent) function.
+//
+// The context.Context the template is executed with (see Template.ExecuteContext)
+// is available through Context(); long-running tags and filters should honor it.
 type ExecutionContext struct {
-	template   *Template
-	macroDepth int
+	template     *Template
+	inheritance  []*Template               // the executed template and all of its parents (the last one is the executed base template)
+	slots        map[string]*componentSlot // slots filled by the caller of a component ({% component %})
+	fragment     *blockFragment            // nil unless only a single block is rendered (see Template.ExecuteBlock)
+	macroDepth   int
+	includeDepth int
+	goctx        context.Context
+	limits       *executionLimits // nil if the set has no Limits
+
+	strictUndefined bool // see Options.StrictUndefined
+
+	// undefinedGuard overrides strictUndefined while evaluating guards (see
+	// allowUndefined and reportUndefined). It's not inherited by child contexts,
+	// so macros called by a guard are executed as usual.
+	undefinedGuard undefinedGuard
 
 	Autoescape bool
 	Public     Context
@@ -77,7 +94,7 @@ var pongo2MetaContext = Context{
 	"version": Version,
 }
 
-func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
+func newExecutionContext(goctx context.Context, tpl *Template, ctx Context) *ExecutionContext {
 	privateCtx := make(Context)
 
 	// Make the pongo2-related funcs/vars available to the context
@@ -85,6 +102,7 @@ func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 
 	return &ExecutionContext{
 		template: tpl,
+		goctx:    goctx,
 
 		Public:     ctx,
 		Private:    privateCtx,
@@ -94,7 +112,15 @@ func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 
 func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	newctx := &ExecutionContext{
-		template: parent.template,
+		template:     parent.template,
+		inheritance:  parent.inheritance,
+		slots:        parent.slots,
+		fragment:     parent.fragment,
+		includeDepth: parent.includeDepth,
+		goctx:        parent.goctx,
+		limits:       parent.limits,
+
+		strictUndefined: parent.strictUndefined,
 
 		Public:     parent.Public,
 		Private:    make(Context),
@@ -108,6 +134,75 @@ func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	return newctx
 }
 
+// Context returns the context.Context the template is executed with. It is never nil;
+// templates executed without an explicit context.Context get context.Background().
+func (ctx *ExecutionContext) Context() context.Context {
+	return ctx.goctx
+}
+
+// TemplateName returns the name of the template being executed (the filename or
+// "<string>"). For templates using inheritance, it's the name of the base template.
+func (ctx *ExecutionContext) TemplateName() string {
+	return ctx.template.name
+}
+
+// checkCanceled returns an error if the execution's context.Context has been
+// canceled or its deadline (or the set's MaxExecutionTime) has been exceeded.
+func (ctx *ExecutionContext) checkCanceled(token *Token) *Error {
+	select {
+	case <-ctx.goctx.Done():
+		if ctx.executionTimeExceeded() {
+			return ctx.limitError(LimitExecutionTime, int64(ctx.limits.limits.MaxExecutionTime), token)
+		}
+		return ctx.OrigError(ctx.goctx.Err(), token)
+	default:
+		return nil
+	}
+}
+
+type undefinedGuard int
+
+const (
+	undefinedNotGuarded undefinedGuard = iota
+	undefinedAllowed
+	undefinedReported
+)
+
+// undefinedIsError reports whether resolving an undefined variable results in an error.
+func (ctx *ExecutionContext) undefinedIsError() bool {
+	switch ctx.undefinedGuard {
+	case undefinedAllowed:
+		return false
+	case undefinedReported:
+		return true
+	}
+	return ctx.strictUndefined
+}
+
+// allowUndefined returns an ExecutionContext which resolves undefined variables to
+// empty values even if Options.StrictUndefined is set. It's used to evaluate
+// guards like {% if var %} or {{ var|default:"..." }}.
+func (ctx *ExecutionContext) allowUndefined() *ExecutionContext {
+	if !ctx.undefinedIsError() {
+		return ctx
+	}
+	lenientCtx := *ctx
+	lenientCtx.undefinedGuard = undefinedAllowed
+	return &lenientCtx
+}
+
+// reportUndefined returns an ExecutionContext which reports undefined variables
+// as errors (like Options.StrictUndefined does). It's used by the is-operator
+// to tell undefined variables from NIL values.
+func (ctx *ExecutionContext) reportUndefined() *ExecutionContext {
+	if ctx.undefinedIsError() {
+		return ctx
+	}
+	strictCtx := *ctx
+	strictCtx.undefinedGuard = undefinedReported
+	return &strictCtx
+}
+
 func (ctx *ExecutionContext) Error(msg string, token *Token) *Error {
 	return ctx.OrigError(errors.New(msg), token)
 }
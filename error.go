@@ -2,8 +2,10 @@ package pongo2
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // The Error type is being used to address an error during lexing, parsing or
@@ -58,6 +60,11 @@ func (e *Error) Error() string {
 	return s
 }
 
+// Unwrap returns the original error (e. g. to check for context.Canceled using errors.Is()).
+func (e *Error) Unwrap() error {
+	return e.OrigError
+}
+
 // RawLine returns the affected line from the original template, if available.
 func (e *Error) RawLine() (line string, available bool, outErr error) {
 	if e.Line <= 0 || e.Filename == "<string>" {
@@ -89,3 +96,29 @@ func (e *Error) RawLine() (line string, available bool, outErr error) {
 	}
 	return "", false, nil
 }
+
+// TemplateNotFoundError is the OrigError of an *Error returned if none of the
+// set's loaders can find a template (use errors.As() to check for it). It allows
+// to tell a missing template apart from one which failed to compile.
+type TemplateNotFoundError struct {
+	// Names of the templates looked up (more than one for fallback lists
+	// like {% include ["custom/row.html", "row.html"] %})
+	Names []string
+}
+
+func (e *TemplateNotFoundError) Error() string {
+	if len(e.Names) == 1 {
+		return fmt.Sprintf("template '%s' not found", e.Names[0])
+	}
+	return fmt.Sprintf("none of the templates ('%s') found", strings.Join(e.Names, "', '"))
+}
+
+// isTemplateNotFound reports whether err is a TemplateNotFoundError for the
+// template with the given name (and not, e. g., for one included by it).
+func isTemplateNotFound(err error, name string) bool {
+	var notFound *TemplateNotFoundError
+	if !errors.As(err, &notFound) {
+		return false
+	}
+	return len(notFound.Names) == 1 && notFound.Names[0] == name
+}
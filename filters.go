@@ -7,10 +7,154 @@ import (
 // FilterFunction is the type filter functions must fulfil
 type FilterFunction func(in *Value, param *Value) (out *Value, err *Error)
 
-var filters map[string]FilterFunction
+// ContextFilterFunction is the type of filter functions which need access to
+// the current ExecutionContext (e. g. to the autoescape state, the template or
+// request-scoped data provided through ExecutionContext.Context() or the
+// Public context). Register them using RegisterContextFilter().
+//
+// ctx is nil if the filter is applied outside of a template execution
+// (e. g. using ApplyFilter()).
+type ContextFilterFunction func(ctx *ExecutionContext, in *Value, param *Value) (out *Value, err *Error)
+
+// ArgsFilterFunction is the type of filter functions accepting multiple positional
+// and keyword arguments, e. g. {{ text|truncate(30, end="…") }}. The arguments are
+// bound to the parameters declared on registration (see RegisterArgsFilter()).
+//
+// As with ContextFilterFunction, ctx is nil if the filter is applied outside of
+// a template execution.
+type ArgsFilterFunction func(ctx *ExecutionContext, in *Value, args *FilterArgs) (out *Value, err *Error)
+
+// FilterParameter declares a parameter of a filter registered using RegisterArgsFilter().
+type FilterParameter struct {
+	Name string
+
+	// Default is used if the argument isn't given and the parameter is not required.
+	Default any
+
+	// Required parameters must be given by the template (checked at parse time).
+	Required bool
+}
+
+// FilterArgs holds the arguments of a single filter call, bound to the
+// parameters declared by the filter.
+type FilterArgs struct {
+	values map[string]*Value
+	given  map[string]bool
+}
+
+// Get returns the value of the given parameter (or its default if the argument
+// wasn't given). Returns a nil-*Value if there is no such parameter.
+func (args *FilterArgs) Get(name string) *Value {
+	if v, has := args.values[name]; has {
+		return v
+	}
+	return AsValue(nil)
+}
+
+// IsGiven returns true if the argument for the given parameter has been passed
+// explicitly by the template.
+func (args *FilterArgs) IsGiven(name string) bool {
+	return args.given[name]
+}
+
+// filter is a registered filter. Either fn (classic filters taking one optional
+// parameter) or argsFn (filters declaring their parameters) is set.
+type filter struct {
+	fn     ContextFilterFunction
+	argsFn ArgsFilterFunction
+	params []FilterParameter
+}
+
+// maxPositional returns the number of positional arguments the filter accepts.
+func (f *filter) maxPositional() int {
+	if f.argsFn == nil {
+		return 1
+	}
+	return len(f.params)
+}
+
+// paramIndex returns the index of the given parameter or -1.
+func (f *filter) paramIndex(name string) int {
+	for idx, param := range f.params {
+		if param.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// bindArgs creates the FilterArgs out of the evaluated arguments (nil entries
+// haven't been given).
+func (f *filter) bindArgs(values []*Value) (*FilterArgs, error) {
+	args := &FilterArgs{
+		values: make(map[string]*Value, len(f.params)),
+		given:  make(map[string]bool, len(f.params)),
+	}
+	for idx, param := range f.params {
+		if idx < len(values) && values[idx] != nil {
+			args.values[param.Name] = values[idx]
+			args.given[param.Name] = true
+			continue
+		}
+		if param.Required {
+			return nil, fmt.Errorf("missing required argument '%s'", param.Name)
+		}
+		args.values[param.Name] = AsValue(param.Default)
+	}
+	return args, nil
+}
+
+// apply calls the filter with a single (optional) parameter as done by
+// ApplyFilter() and the filter-tag. For filters declaring their parameters,
+// param is bound to the first one.
+func (f *filter) apply(ctx *ExecutionContext, name string, value *Value, param *Value) (*Value, *Error) {
+	if f.argsFn == nil {
+		// Make sure param is a *Value
+		if param == nil {
+			param = AsValue(nil)
+		}
+		return f.fn(ctx, value, param)
+	}
+
+	var values []*Value
+	if param != nil {
+		values = []*Value{param}
+	}
+	args, err := f.bindArgs(values)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:" + name,
+			OrigError: err,
+		}
+	}
+	return f.argsFn(ctx, value, args)
+}
+
+func checkFilterParameters(params []FilterParameter) error {
+	seen := make(map[string]bool, len(params))
+	for _, param := range params {
+		if param.Name == "" {
+			return fmt.Errorf("filter parameters must have a name")
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("filter parameter '%s' is declared twice", param.Name)
+		}
+		seen[param.Name] = true
+	}
+	return nil
+}
+
+// All filters are stored as *filter internally.
+var filters map[string]*filter
 
 func init() {
-	filters = make(map[string]FilterFunction)
+	filters = make(map[string]*filter)
+}
+
+func (fn FilterFunction) withContext() ContextFilterFunction {
+	return func(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+		return fn(in, param)
+	}
 }
 
 // FilterExists returns true if the given filter is already registered
@@ -24,20 +168,52 @@ func FilterExists(name string) bool {
 //
 //	http://golang.org/doc/effective_go.html#init
 func RegisterFilter(name string, fn FilterFunction) error {
+	return RegisterContextFilter(name, fn.withContext())
+}
+
+// RegisterContextFilter registers a new filter which gets access to the current
+// ExecutionContext. See RegisterFilter() for more information.
+func RegisterContextFilter(name string, fn ContextFilterFunction) error {
 	if FilterExists(name) {
 		return fmt.Errorf("filter with name '%s' is already registered", name)
 	}
-	filters[name] = fn
+	filters[name] = &filter{fn: fn}
+	return nil
+}
+
+// RegisterArgsFilter registers a new filter accepting the declared parameters as
+// positional and/or keyword arguments:
+//
+//	{{ text|truncate(30) }}
+//	{{ text|truncate(30, end="…") }}
+//	{{ text|truncate(length=30) }}
+//
+// The Django colon form ({{ text|truncate:30 }}) is supported as well and binds
+// the argument to the first parameter. See RegisterFilter() for more information.
+func RegisterArgsFilter(name string, params []FilterParameter, fn ArgsFilterFunction) error {
+	if FilterExists(name) {
+		return fmt.Errorf("filter with name '%s' is already registered", name)
+	}
+	if err := checkFilterParameters(params); err != nil {
+		return err
+	}
+	filters[name] = &filter{argsFn: fn, params: params}
 	return nil
 }
 
 // ReplaceFilter replaces an already registered filter with a new implementation. Use this
 // function with caution since it allows you to change existing filter behaviour.
 func ReplaceFilter(name string, fn FilterFunction) error {
+	return ReplaceContextFilter(name, fn.withContext())
+}
+
+// ReplaceContextFilter behaves like ReplaceFilter, but the new implementation
+// gets access to the current ExecutionContext.
+func ReplaceContextFilter(name string, fn ContextFilterFunction) error {
 	if !FilterExists(name) {
 		return fmt.Errorf("filter with name '%s' does not exist (therefore cannot be overridden)", name)
 	}
-	filters[name] = fn
+	filters[name] = &filter{fn: fn}
 	return nil
 }
 
@@ -51,9 +227,10 @@ func MustApplyFilter(name string, value *Value, param *Value) *Value {
 }
 
 // ApplyFilter applies a filter to a given value using the given parameters.
-// Returns a *pongo2.Value or an error.
+// Returns a *pongo2.Value or an error. For filters registered using
+// RegisterArgsFilter(), param is passed as the first argument.
 func ApplyFilter(name string, value *Value, param *Value) (*Value, *Error) {
-	fn, existing := filters[name]
+	f, existing := filters[name]
 	if !existing {
 		return nil, &Error{
 			Sender:    "applyfilter",
@@ -61,12 +238,7 @@ func ApplyFilter(name string, value *Value, param *Value) (*Value, *Error) {
 		}
 	}
 
-	// Make sure param is a *Value
-	if param == nil {
-		param = AsValue(nil)
-	}
-
-	return fn(value, param)
+	return f.apply(nil, name, value, param)
 }
 
 type filterCall struct {
@@ -75,30 +247,53 @@ type filterCall struct {
 	name      string
 	parameter IEvaluator
 
-	filterFunc FilterFunction
+	// args are the arguments bound to the filter's parameters (only used for
+	// filters registered using RegisterArgsFilter(); nil entries haven't been given)
+	args []IEvaluator
+
+	filter *filter
 }
 
 func (fc *filterCall) Execute(v *Value, ctx *ExecutionContext) (*Value, *Error) {
-	var param *Value
+	var filteredValue *Value
 	var err *Error
 
-	if fc.parameter != nil {
-		param, err = fc.parameter.Evaluate(ctx)
-		if err != nil {
-			return nil, err
+	if fc.filter.argsFn != nil {
+		values := make([]*Value, len(fc.args))
+		for idx, arg := range fc.args {
+			if arg == nil {
+				continue
+			}
+			values[idx], err = arg.Evaluate(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		args, bindErr := fc.filter.bindArgs(values)
+		if bindErr != nil {
+			return nil, ctx.OrigError(bindErr, fc.token)
 		}
+		filteredValue, err = fc.filter.argsFn(ctx, v, args)
 	} else {
-		param = AsValue(nil)
+		param := AsValue(nil)
+		if fc.parameter != nil {
+			param, err = fc.parameter.Evaluate(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		filteredValue, err = fc.filter.fn(ctx, v, param)
 	}
 
-	filteredValue, err := fc.filterFunc(v, param)
 	if err != nil {
 		return nil, err.updateFromTokenIfNeeded(ctx.template, fc.token)
 	}
 	return filteredValue, nil
 }
 
-// Filter = IDENT | IDENT ":" FilterArg | IDENT "|" Filter
+// Filter = IDENT | IDENT ":" FilterArg | IDENT "(" FilterArgs ")" | IDENT "|" Filter
+// FilterArgs = [ FilterArg { "," FilterArg } ]
+// FilterArg = Expression | IDENT "=" Expression
 func (p *Parser) parseFilter() (*filterCall, *Error) {
 	identToken := p.MatchType(TokenIdentifier)
 
@@ -107,18 +302,22 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 		return nil, p.Error("Filter name must be an identifier.", nil)
 	}
 
-	filter := &filterCall{
+	fc := &filterCall{
 		token: identToken,
 		name:  identToken.Val,
 	}
 
-	// Get the appropriate filter function and bind it
-	filterFn, exists := filters[identToken.Val]
+	// Get the appropriate filter (set-local filters first, then the global
+	// ones) and bind it
+	f, exists := p.template.set.filter(identToken.Val)
 	if !exists {
 		return nil, p.Error(fmt.Sprintf("Filter '%s' does not exist.", identToken.Val), identToken)
 	}
 
-	filter.filterFunc = filterFn
+	fc.filter = f
+	if f.argsFn != nil {
+		fc.args = make([]IEvaluator, len(f.params))
+	}
 
 	// Check for filter-argument (2 tokens needed: ':' ARG)
 	if p.Match(TokenSymbol, ":") != nil {
@@ -131,8 +330,92 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 		if err != nil {
 			return nil, err
 		}
-		filter.parameter = v
+		if err := fc.bindPositional(p, 0, v); err != nil {
+			return nil, err
+		}
+	} else if p.Match(TokenSymbol, "(") != nil {
+		if err := fc.parseArguments(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.argsFn != nil {
+		for idx, param := range f.params {
+			if param.Required && fc.args[idx] == nil {
+				return nil, p.Error(fmt.Sprintf("Filter '%s' requires argument '%s'.", fc.name, param.Name), identToken)
+			}
+		}
 	}
 
-	return filter, nil
+	return fc, nil
+}
+
+// parseArguments parses the argument list of a filter call after the opening "(".
+func (fc *filterCall) parseArguments(p *Parser) *Error {
+	positional := 0
+	keywordSeen := false
+
+	for p.Match(TokenSymbol, ")") == nil {
+		if positional > 0 || keywordSeen {
+			if p.Match(TokenSymbol, ",") == nil {
+				return p.Error("Expected ',' or ')' in filter argument list.", nil)
+			}
+		}
+
+		if p.PeekTypeN(0, TokenIdentifier) != nil && p.PeekN(1, TokenSymbol, "=") != nil {
+			// Keyword argument
+			keyToken := p.MatchType(TokenIdentifier)
+			p.Consume() // "="
+			expr, err := p.ParseExpression()
+			if err != nil {
+				return err
+			}
+			if err := fc.bindKeyword(p, keyToken, expr); err != nil {
+				return err
+			}
+			keywordSeen = true
+			continue
+		}
+
+		if keywordSeen {
+			return p.Error(fmt.Sprintf("Filter '%s': positional argument follows keyword argument.", fc.name), nil)
+		}
+		expr, err := p.ParseExpression()
+		if err != nil {
+			return err
+		}
+		if err := fc.bindPositional(p, positional, expr); err != nil {
+			return err
+		}
+		positional++
+	}
+
+	return nil
+}
+
+func (fc *filterCall) bindPositional(p *Parser, idx int, expr IEvaluator) *Error {
+	if idx >= fc.filter.maxPositional() {
+		return p.Error(fmt.Sprintf("Filter '%s' takes at most %d argument(s).", fc.name, fc.filter.maxPositional()), fc.token)
+	}
+	if fc.filter.argsFn == nil {
+		fc.parameter = expr
+		return nil
+	}
+	fc.args[idx] = expr
+	return nil
+}
+
+func (fc *filterCall) bindKeyword(p *Parser, keyToken *Token, expr IEvaluator) *Error {
+	idx := -1
+	if fc.filter.argsFn != nil {
+		idx = fc.filter.paramIndex(keyToken.Val)
+	}
+	if idx < 0 {
+		return p.Error(fmt.Sprintf("Filter '%s' has no parameter '%s'.", fc.name, keyToken.Val), keyToken)
+	}
+	if fc.args[idx] != nil {
+		return p.Error(fmt.Sprintf("Filter '%s' got multiple values for argument '%s'.", fc.name, keyToken.Val), keyToken)
+	}
+	fc.args[idx] = expr
+	return nil
 }
@@ -93,8 +93,14 @@ func init() {
 	RegisterFilter("wordwrap", filterWordwrap)
 	RegisterFilter("yesno", filterYesno)
 
-	RegisterFilter("float", filterFloat)     // pongo-specific
-	RegisterFilter("integer", filterInteger) // pongo-specific
+	RegisterFilter("float", filterFloat)         // pongo-specific
+	RegisterFilter("integer", filterInteger)     // pongo-specific
+	RegisterFilter("enumerate", filterEnumerate) // pongo-specific
+
+	RegisterArgsFilter("truncate", []FilterParameter{ // jinja2-like
+		{Name: "length", Default: 255},
+		{Name: "end", Default: "..."},
+	}, filterTruncate)
 }
 
 func filterTruncatecharsHelper(s string, newLen int) string {
@@ -224,6 +230,22 @@ func filterTruncatechars(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(filterTruncatecharsHelper(s, newLen)), nil
 }
 
+func filterTruncate(ctx *ExecutionContext, in *Value, args *FilterArgs) (*Value, *Error) {
+	runes := []rune(in.String())
+	length := args.Get("length").Integer()
+	end := []rune(args.Get("end").String())
+
+	if length < 0 || len(runes) <= length {
+		return AsValue(string(runes)), nil
+	}
+
+	// The end is part of the given length
+	if len(end) > length {
+		end = end[:length]
+	}
+	return AsValue(string(runes[:length-len(end)]) + string(end)), nil
+}
+
 func filterTruncatecharsHTML(in *Value, param *Value) (*Value, *Error) {
 	value := in.String()
 	newLen := max(param.Integer()-3, 0)
@@ -432,6 +454,19 @@ func filterDivisibleby(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(in.Integer()%param.Integer() == 0), nil
 }
 
+// filterEnumerate turns the items into (index, item)-pairs to be unpacked
+// by a for-loop ({% for idx, item in items|enumerate %}). The index starts
+// with the given parameter (default: 0).
+func filterEnumerate(in *Value, param *Value) (*Value, *Error) {
+	start := param.Integer()
+	pairs := make([]any, 0, in.Len())
+	in.Iterate(func(idx, count int, key, value *Value) bool {
+		pairs = append(pairs, [2]any{start + idx, key.Interface()})
+		return true
+	}, func() {})
+	return AsValue(pairs), nil
+}
+
 func filterFirst(in *Value, param *Value) (*Value, *Error) {
 	if in.CanSlice() && in.Len() > 0 {
 		return in.Index(0), nil
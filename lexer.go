@@ -36,7 +36,7 @@ var (
 		"==", ">=", "<=", "&&", "||", "{{", "}}", "{%", "%}", "!=", "<>",
 
 		// 1-Char symbol
-		"(", ")", "+", "-", "*", "<", ">", "/", "^", ",", ".", "!", "|", ":", "=", "%", "[", "]",
+		"(", ")", "+", "-", "*", "<", ">", "/", "^", ",", ".", "!", "|", ":", "=", "%", "[", "]", "{", "}",
 	}
 
 	// Available keywords in pongo2
@@ -72,6 +72,9 @@ type (
 
 		inVerbatim   bool
 		verbatimName string
+
+		// braceDepth counts the open map literals ("{") within a tag/variable
+		braceDepth int
 	}
 )
 
@@ -320,6 +323,7 @@ func (l *lexer) run() {
 }
 
 func (l *lexer) tokenize() {
+	l.braceDepth = 0
 	for state := l.stateCode; state != nil; {
 		state = state()
 	}
@@ -346,6 +350,17 @@ outer_loop:
 		// Check for symbol
 		for _, sym := range TokenSymbols {
 			if strings.HasPrefix(l.input[l.start:], sym) {
+				// "}}" closes a map literal first (e. g. {{ {"a": {"b": 1}} }})
+				if sym == "}}" && l.braceDepth > 0 {
+					sym = "}"
+				}
+				switch sym {
+				case "{":
+					l.braceDepth++
+				case "}":
+					l.braceDepth--
+				}
+
 				l.pos += len(sym)
 				l.col += l.length()
 				l.emit(TokenSymbol)
@@ -0,0 +1,200 @@
+package pongo2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits restrict the resources a single template execution may consume. They are
+// configured per TemplateSet (see TemplateSet.Limits) and are especially useful for
+// sandboxed sets which execute user-provided templates. A zero value for any field
+// means "unlimited".
+//
+// Once a limit is hit, the execution is aborted and an *Error is returned whose
+// OrigError is a *LimitError (use errors.As() to check for it).
+type Limits struct {
+	// MaxOutputBytes is the maximum number of bytes written to the writer
+	// passed to the Execute*-functions. Output buffered during the execution (e. g.
+	// by included templates, macros or the filter tag) counts towards it as well.
+	MaxOutputBytes int64
+
+	// MaxLoopIterations is the maximum number of for-loop iterations in total
+	// (summed up over all loops, including nested ones and loops in included templates).
+	MaxLoopIterations int
+
+	// MaxIncludeDepth is the maximum nesting depth of included templates.
+	MaxIncludeDepth int
+
+	// MaxExecutionTime is the maximum wall time an execution may take.
+	MaxExecutionTime time.Duration
+}
+
+func (l *Limits) active() bool {
+	return *l != (Limits{})
+}
+
+// Limit identifies one of the limits of Limits.
+type Limit int
+
+const (
+	LimitOutputBytes Limit = iota + 1
+	LimitLoopIterations
+	LimitIncludeDepth
+	LimitExecutionTime
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitOutputBytes:
+		return "MaxOutputBytes"
+	case LimitLoopIterations:
+		return "MaxLoopIterations"
+	case LimitIncludeDepth:
+		return "MaxIncludeDepth"
+	case LimitExecutionTime:
+		return "MaxExecutionTime"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// LimitError is the OrigError of an *Error returned when a template execution
+// exceeded one of the configured Limits.
+type LimitError struct {
+	Limit Limit
+
+	// Max is the configured maximum (bytes, iterations, depth or a time.Duration).
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitExecutionTime {
+		return fmt.Sprintf("resource limit %s exceeded (max is %s)", e.Limit, time.Duration(e.Max))
+	}
+	return fmt.Sprintf("resource limit %s exceeded (max is %d)", e.Limit, e.Max)
+}
+
+// executionLimits keeps track of the resources used by one execution. It's shared
+// among all ExecutionContexts of the execution (including included templates).
+type executionLimits struct {
+	limits Limits
+
+	// parentCtx is the context.Context given by the user; the ExecutionContext's one
+	// is derived from it if MaxExecutionTime is set.
+	parentCtx context.Context
+
+	outputBytes    int64
+	pendingBytes   int64 // output buffered (e. g. by included templates or macros), not written yet
+	outputExceeded bool
+	loopIterations int
+}
+
+// newExecutionLimits returns the tracking of the limits for an execution (nil if
+// no limits are configured) and the context.Context to execute with, which
+// honors MaxExecutionTime. cancel must be called once the execution has finished.
+func newExecutionLimits(limits Limits, goctx context.Context) (*executionLimits, context.Context, context.CancelFunc) {
+	if !limits.active() {
+		return nil, goctx, func() {}
+	}
+	l := &executionLimits{
+		limits:    limits,
+		parentCtx: goctx,
+	}
+	if limits.MaxExecutionTime > 0 {
+		goctx, cancel := context.WithTimeout(goctx, limits.MaxExecutionTime)
+		return l, goctx, cancel
+	}
+	return l, goctx, func() {}
+}
+
+// writer returns w restricted by MaxOutputBytes (if any). The output written to a
+// pending writer (a buffer, e. g. of an included template or a macro) is accounted as
+// pending until it's released (and possibly written to the enclosing writer).
+func (l *executionLimits) writer(w TemplateWriter, pending bool) TemplateWriter {
+	if l == nil || l.limits.MaxOutputBytes <= 0 {
+		return w
+	}
+	return &limitedTemplateWriter{w: w, limits: l, pending: pending}
+}
+
+// releasePending accounts n bytes of a pending writer as not being pending anymore.
+func (l *executionLimits) releasePending(n int) {
+	if l != nil {
+		l.pendingBytes -= int64(n)
+	}
+}
+
+func (ctx *ExecutionContext) limitError(limit Limit, max int64, token *Token) *Error {
+	err := ctx.OrigError(&LimitError{Limit: limit, Max: max}, token)
+	err.Sender = "limit"
+	return err
+}
+
+// checkOutputLimit returns an error if the output limit has been exceeded by a previous write.
+func (ctx *ExecutionContext) checkOutputLimit(token *Token) *Error {
+	if ctx.limits == nil || !ctx.limits.outputExceeded {
+		return nil
+	}
+	return ctx.limitError(LimitOutputBytes, ctx.limits.limits.MaxOutputBytes, token)
+}
+
+// countLoopIteration accounts one for-loop iteration and returns an error if
+// the iteration limit has been exceeded.
+func (ctx *ExecutionContext) countLoopIteration(token *Token) *Error {
+	if ctx.limits == nil || ctx.limits.limits.MaxLoopIterations <= 0 {
+		return nil
+	}
+	ctx.limits.loopIterations++
+	if ctx.limits.loopIterations > ctx.limits.limits.MaxLoopIterations {
+		return ctx.limitError(LimitLoopIterations, int64(ctx.limits.limits.MaxLoopIterations), token)
+	}
+	return nil
+}
+
+// checkIncludeDepth returns an error if including another template would exceed the include depth limit.
+func (ctx *ExecutionContext) checkIncludeDepth(token *Token) *Error {
+	if ctx.limits == nil || ctx.limits.limits.MaxIncludeDepth <= 0 {
+		return nil
+	}
+	if ctx.includeDepth+1 > ctx.limits.limits.MaxIncludeDepth {
+		return ctx.limitError(LimitIncludeDepth, int64(ctx.limits.limits.MaxIncludeDepth), token)
+	}
+	return nil
+}
+
+// executionTimeExceeded reports whether the ExecutionContext's context.Context is done
+// because of MaxExecutionTime (and not because the user's context.Context is done).
+func (ctx *ExecutionContext) executionTimeExceeded() bool {
+	return ctx.limits != nil && ctx.limits.limits.MaxExecutionTime > 0 &&
+		errors.Is(ctx.goctx.Err(), context.DeadlineExceeded) && ctx.limits.parentCtx.Err() == nil
+}
+
+// limitedTemplateWriter stops writing once the output limit has been reached. As
+// nodes are not checking the result of their writes, the exceeded limit is
+// recorded and reported by the next node (see NodeWrapper and nodeDocument).
+type limitedTemplateWriter struct {
+	w       TemplateWriter
+	limits  *executionLimits
+	pending bool
+}
+
+var errOutputLimitExceeded = errors.New("output limit exceeded")
+
+func (tw *limitedTemplateWriter) Write(b []byte) (int, error) {
+	l := tw.limits
+	if l.outputExceeded || l.outputBytes+l.pendingBytes+int64(len(b)) > l.limits.MaxOutputBytes {
+		l.outputExceeded = true
+		return 0, errOutputLimitExceeded
+	}
+	if tw.pending {
+		l.pendingBytes += int64(len(b))
+	} else {
+		l.outputBytes += int64(len(b))
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *limitedTemplateWriter) WriteString(s string) (int, error) {
+	return tw.Write([]byte(s))
+}
@@ -2,15 +2,19 @@ package pongo2
 
 // The root document
 type nodeDocument struct {
-	Nodes []INode
+	Nodes     []INode
+	positions []*Token // position of each node (for error reporting)
 }
 
 func (doc *nodeDocument) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	for _, n := range doc.Nodes {
+	for idx, n := range doc.Nodes {
 		err := n.Execute(ctx, writer)
 		if err != nil {
 			return err
 		}
+		if err := ctx.checkOutputLimit(doc.positions[idx]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
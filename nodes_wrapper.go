@@ -1,16 +1,20 @@
 package pongo2
 
 type NodeWrapper struct {
-	Endtag string
-	nodes  []INode
+	Endtag    string
+	nodes     []INode
+	positions []*Token // position of each node (for error reporting)
 }
 
 func (wrapper *NodeWrapper) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	for _, n := range wrapper.nodes {
+	for idx, n := range wrapper.nodes {
 		err := n.Execute(ctx, writer)
 		if err != nil {
 			return err
 		}
+		if err := ctx.checkOutputLimit(wrapper.positions[idx]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -8,12 +8,19 @@ type Options struct {
 
 	// If this is set to true leading spaces and tabs are stripped from the start of a line to a block. Defaults to false
 	LStripBlocks bool
+
+	// If this is set to true, variables which can't be resolved (unknown names, missing map keys or
+	// struct fields, out-of-range indexes) lead to an execution error instead of an empty value.
+	// Lookups guarded by the default/default_if_none filters or by if/elif conditions are still
+	// allowed to be undefined. Defaults to false.
+	StrictUndefined bool
 }
 
 func newOptions() *Options {
 	return &Options{
-		TrimBlocks:   false,
-		LStripBlocks: false,
+		TrimBlocks:      false,
+		LStripBlocks:    false,
+		StrictUndefined: false,
 	}
 }
 
@@ -21,6 +28,7 @@ func newOptions() *Options {
 func (opt *Options) Update(other *Options) *Options {
 	opt.TrimBlocks = other.TrimBlocks
 	opt.LStripBlocks = other.LStripBlocks
+	opt.StrictUndefined = other.StrictUndefined
 
 	return opt
 }
@@ -35,6 +35,10 @@ type Parser struct {
 	// if the parser parses a template document, here will be
 	// a reference to it (needed to access the template through Tags)
 	template *Template
+
+	// loopDepth is the number of for-loops enclosing the currently parsed
+	// position (used to validate {% break %} and {% continue %})
+	loopDepth int
 }
 
 // Creates a new parser to parse tokens.
@@ -254,11 +258,13 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 		}
 
 		// Otherwise process next element to be wrapped
+		position := p.elementPosition()
 		node, err := p.parseDocElement()
 		if err != nil {
 			return nil, nil, err
 		}
 		wrapper.nodes = append(wrapper.nodes, node)
+		wrapper.positions = append(wrapper.positions, position)
 	}
 
 	return nil, nil, p.Error(fmt.Sprintf("Unexpected EOF, expected tag %s.", strings.Join(names, " or ")),
@@ -34,6 +34,18 @@ func (p *Parser) parseDocElement() (INode, *Error) {
 	return nil, p.Error("Unexpected token (only HTML/tags/filters in templates allowed)", t)
 }
 
+// elementPosition returns the token which describes the position of the document
+// element starting at the current token best (e. g. the tag name instead of '{%').
+func (p *Parser) elementPosition() *Token {
+	t := p.Current()
+	if t != nil && t.Typ == TokenSymbol {
+		if next := p.GetR(1); next != nil {
+			return next
+		}
+	}
+	return t
+}
+
 func (tpl *Template) parse() *Error {
 	tpl.parser = newParser(tpl.name, tpl.tokens, tpl)
 	doc, err := tpl.parser.parseDocument()
@@ -48,11 +60,13 @@ func (p *Parser) parseDocument() (*nodeDocument, *Error) {
 	doc := &nodeDocument{}
 
 	for p.Remaining() > 0 {
+		position := p.elementPosition()
 		node, err := p.parseDocElement()
 		if err != nil {
 			return nil, err
 		}
 		doc.Nodes = append(doc.Nodes, node)
+		doc.positions = append(doc.positions, position)
 	}
 
 	return doc, nil
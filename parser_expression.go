@@ -1,6 +1,7 @@
 package pongo2
 
 import (
+	"errors"
 	"fmt"
 	"math"
 )
@@ -12,6 +13,22 @@ type Expression struct {
 	opToken *Token
 }
 
+// conditionalExpression is an inline if: expr1 if condition else expr2
+type conditionalExpression struct {
+	expr1     IEvaluator
+	condition IEvaluator
+	expr2     IEvaluator // optional; nil if there is no else-branch
+}
+
+// isExpression applies a test: operand is [not] test [param]
+type isExpression struct {
+	operand   IEvaluator
+	negate    bool
+	nameToken *Token
+	testFn    TestFunction
+	param     IEvaluator // optional
+}
+
 type relationalExpression struct {
 	// TODO: Add location token?
 	expr1   IEvaluator
@@ -45,6 +62,15 @@ func (expr *Expression) FilterApplied(name string) bool {
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
 }
 
+func (expr *conditionalExpression) FilterApplied(name string) bool {
+	// The else-branch evaluates to an empty value if it's missing
+	return expr.expr1.FilterApplied(name) && (expr.expr2 == nil || expr.expr2.FilterApplied(name))
+}
+
+func (expr *isExpression) FilterApplied(name string) bool {
+	return false
+}
+
 func (expr *relationalExpression) FilterApplied(name string) bool {
 	return expr.expr1.FilterApplied(name) && (expr.expr2 == nil ||
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
@@ -69,6 +95,14 @@ func (expr *Expression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
 
+func (expr *conditionalExpression) GetPositionToken() *Token {
+	return expr.expr1.GetPositionToken()
+}
+
+func (expr *isExpression) GetPositionToken() *Token {
+	return expr.operand.GetPositionToken()
+}
+
 func (expr *relationalExpression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
@@ -94,6 +128,24 @@ func (expr *Expression) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 	return nil
 }
 
+func (expr *conditionalExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (expr *isExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (expr *relationalExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -165,6 +217,61 @@ func (expr *Expression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	}
 }
 
+func (expr *conditionalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	// Like in the if-tag, the condition is allowed to check for undefined
+	// variables (see Options.StrictUndefined)
+	cond, err := expr.condition.Evaluate(ctx.allowUndefined())
+	if err != nil {
+		return nil, err
+	}
+	// Only the chosen branch is evaluated
+	branch := expr.expr1
+	if !cond.IsTrue() {
+		if expr.expr2 == nil {
+			return AsValue(nil), nil
+		}
+		branch = expr.expr2
+	}
+
+	value, err := branch.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// FilterApplied() can only tell whether both branches are safe; mark the
+	// value as safe if the chosen one is, so autoescape is decided per branch.
+	if !value.safe && branch.FilterApplied("safe") {
+		value = &Value{val: value.val, safe: true}
+	}
+	return value, nil
+}
+
+func (expr *isExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	// The operand is evaluated reporting undefined variables, so tests
+	// like 'defined' can tell them from NIL values
+	value, err := expr.operand.Evaluate(ctx.reportUndefined())
+	if err != nil {
+		var undefinedErr *undefinedVariableError
+		if !errors.As(err, &undefinedErr) {
+			return nil, err
+		}
+		value = &Value{undefined: true}
+	}
+
+	param := AsValue(nil)
+	if expr.param != nil {
+		param, err = expr.param.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := expr.testFn(value, param)
+	if err != nil {
+		return nil, err.updateFromTokenIfNeeded(ctx.template, expr.nameToken)
+	}
+	return AsValue(result != expr.negate), nil
+}
+
 func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	v1, err := expr.expr1.Evaluate(ctx)
 	if err != nil {
@@ -493,6 +600,20 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 		}
 		expr.opToken = t
 		expr.expr2 = expr2
+	} else if p.Match(TokenIdentifier, "is") != nil {
+		// A test binds tighter than a leading not/! (like in Jinja): 'not x is defined'
+		// is 'not (x is defined)'
+		negate := false
+		if simple, isSimple := expr1.(*simpleExpression); isSimple && simple.negate {
+			operand := *simple
+			operand.negate = false
+			expr1 = &operand
+			if !operand.negativeSign && operand.term2 == nil {
+				expr1 = operand.term1
+			}
+			negate = true
+		}
+		return p.parseIsExpression(expr1, negate)
 	}
 
 	if expr.expr2 == nil {
@@ -503,7 +624,90 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 	return expr, nil
 }
 
+// IsExpression = SimpleExpression "is" [ "not" ] IDENT [ "(" Expression ")" | TestArg ]
+// TestArg = NUMBER | STRING | "true" | "false" | "[" ... "]" | "{" ... "}"
+//
+// Variables as test arguments must be put into parentheses (e. g. 'x is divisibleby(y)').
+// negate is true if the test is negated by a leading not/! (e. g. 'not x is defined').
+func (p *Parser) parseIsExpression(operand IEvaluator, negate bool) (IEvaluator, *Error) {
+	expr := &isExpression{
+		operand: operand,
+		negate:  negate,
+	}
+
+	if p.Match(TokenKeyword, "not") != nil {
+		expr.negate = !expr.negate
+	}
+
+	nameToken := p.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		// 'in' is a keyword, but a valid test name as well
+		nameToken = p.Match(TokenKeyword, "in")
+	}
+	if nameToken == nil {
+		return nil, p.Error("Test name must be an identifier.", nil)
+	}
+	testFn, exists := p.template.set.test(nameToken.Val)
+	if !exists {
+		return nil, p.Error(fmt.Sprintf("Test '%s' does not exist.", nameToken.Val), nameToken)
+	}
+	expr.nameToken = nameToken
+	expr.testFn = testFn
+
+	if p.Match(TokenSymbol, "(") != nil {
+		param, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.Match(TokenSymbol, ")") == nil {
+			return nil, p.Error("Closing bracket expected after test argument.", nil)
+		}
+		expr.param = param
+	} else if p.PeekType(TokenNumber) != nil || p.PeekType(TokenString) != nil ||
+		p.PeekOne(TokenKeyword, "true", "false") != nil || p.PeekOne(TokenSymbol, "[", "{") != nil {
+		param, err := p.parseVariableOrLiteral()
+		if err != nil {
+			return nil, err
+		}
+		expr.param = param
+	}
+
+	return expr, nil
+}
+
+// ParseExpression parses an expression including an optional inline if:
+//
+//	Expression = OrExpression [ "if" OrExpression [ "else" Expression ] ]
 func (p *Parser) ParseExpression() (IEvaluator, *Error) {
+	expr1, err := p.parseOrExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Match(TokenIdentifier, "if") == nil {
+		return expr1, nil
+	}
+
+	expr := &conditionalExpression{
+		expr1: expr1,
+	}
+
+	expr.condition, err = p.parseOrExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Match(TokenIdentifier, "else") != nil {
+		expr.expr2, err = p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return expr, nil
+}
+
+func (p *Parser) parseOrExpression() (IEvaluator, *Error) {
 	rexpr1, err := p.parseRelationalExpression()
 	if err != nil {
 		return nil, err
@@ -516,7 +720,7 @@ func (p *Parser) ParseExpression() (IEvaluator, *Error) {
 	if p.PeekOne(TokenSymbol, "&&", "||") != nil || p.PeekOne(TokenKeyword, "and", "or") != nil {
 		op := p.Current()
 		p.Consume()
-		expr2, err := p.ParseExpression()
+		expr2, err := p.parseOrExpression()
 		if err != nil {
 			return nil, err
 		}
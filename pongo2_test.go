@@ -1,13 +1,18 @@
 package pongo2_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/randree/pongo2/v7"
 )
@@ -161,3 +166,1069 @@ func FuzzSimpleExecution(f *testing.F) {
 		}
 	})
 }
+
+func TestTemplateSetRegistries(t *testing.T) {
+	web := pongo2.NewSet("web", &DummyLoader{})
+	mail := pongo2.NewSet("mail", &DummyLoader{})
+
+	shout := func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(in.String() + "!"), nil
+	}
+	if err := web.RegisterFilter("shout", shout); err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, web.RegisterFilter("shout", shout).Error(), ".*is already registered in template set 'web'")
+
+	// A set-local filter may shadow a built-in one
+	if err := web.RegisterFilter("upper", shout); err != nil {
+		t.Fatal(err)
+	}
+	if err := web.RegisterTag("hello", tagSandboxDemoTagParser); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := web.RenderTemplateString("{{ name|shout }} {{ name|upper }} {% hello %}", pongo2.Context{"name": "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^john! john! hello$")
+
+	out, err = mail.RenderTemplateString("{{ name|upper }}", pongo2.Context{"name": "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^JOHN$")
+
+	_, err = mail.FromString("{{ name|shout }}")
+	mustEqual(t, fmt.Sprintf("%v", err), ".*Filter 'shout' does not exist.*")
+	_, err = mail.FromString("{% hello %}")
+	mustEqual(t, fmt.Sprintf("%v", err), ".*Tag 'hello' not found.*")
+
+	if !web.FilterExists("shout") || mail.FilterExists("shout") {
+		t.Fatal("FilterExists() does not respect set-local filters")
+	}
+	if !web.TagExists("hello") || mail.TagExists("hello") {
+		t.Fatal("TagExists() does not respect set-local tags")
+	}
+
+	// Set-local filters and tags can be banned as well
+	sandbox := pongo2.NewSet("sandbox", &DummyLoader{})
+	if err := sandbox.RegisterFilter("shout", shout); err != nil {
+		t.Fatal(err)
+	}
+	if err := sandbox.BanFilter("shout"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = sandbox.FromString("{{ name|shout }}")
+	mustEqual(t, fmt.Sprintf("%v", err), ".*Usage of filter 'shout' is not allowed.*")
+}
+
+func TestExecuteContext(t *testing.T) {
+	tpl, err := pongo2.FromString("{% for i in items %}{{ i }}{% if i == 2 %}{{ cancel() }}{% endif %}{% endfor %}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf bytes.Buffer
+	err = tpl.ExecuteWriterUnbufferedContext(goctx, pongo2.Context{
+		"items":  []int{1, 2, 3, 4},
+		"cancel": func() string { cancel(); return "" },
+	}, &buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	mustEqual(t, buf.String(), "^12$")
+
+	// Buffered execution doesn't write anything on cancellation
+	buf.Reset()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	err = tpl.ExecuteContext(expired, pongo2.Context{"items": []int{1}}, &buf)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+	}
+	if buf.Len() > 0 {
+		t.Fatalf("expected no output, got: %s", buf.String())
+	}
+
+	// Macros honor the cancellation as well
+	tpl, err = pongo2.FromString("{% macro rec(n) %}{{ rec(n+1) }}{% endmacro %}{{ rec(0) }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpl.ExecuteContext(expired, nil, &buf)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+	}
+
+	// The context.Context is reachable through the ExecutionContext
+	type ctxKey struct{}
+	tpl, err = pongo2.FromString("{{ locale() }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	err = tpl.ExecuteContext(context.WithValue(context.Background(), ctxKey{}, "de_DE"), pongo2.Context{
+		"locale": func(ctx *pongo2.ExecutionContext) string {
+			return ctx.Context().Value(ctxKey{}).(string)
+		},
+	}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, buf.String(), "^de_DE$")
+
+	// A nil context.Context is treated as context.Background()
+	tpl, err = pongo2.FromString("{% for i in items %}{% block b %}{{ i }}{% endblock %}{% endfor %}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := tpl.ExecuteContext(nil, pongo2.Context{"items": []int{1, 2}}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, buf.String(), "^12$")
+	blocks, err := tpl.ExecuteBlocksContext(nil, pongo2.Context{"i": 3}, []string{"b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, blocks["b"], "^3$")
+}
+
+func TestLimits(t *testing.T) {
+	loader := pongo2.NewFSLoader(fstest.MapFS{
+		"recursive.tpl":       &fstest.MapFile{Data: []byte(`{% include self %}`)},
+		"loop.tpl":            &fstest.MapFile{Data: []byte(`{% for i in items %}{% for j in items %}.{% endfor %}{% endfor %}`)},
+		"include.tpl":         &fstest.MapFile{Data: []byte(`{% include "loop.tpl" %}`)},
+		"filtered.tpl":        &fstest.MapFile{Data: []byte(`{% for i in items if i > 100 %}{{ i }}{% endfor %}`)},
+		"output.tpl":          &fstest.MapFile{Data: []byte("abc{{ text }}\n{{ text }}")},
+		"doubling.tpl":        &fstest.MapFile{Data: []byte(`{% macro double(n) %}{% if n %}{{ double(n-1) }}{{ double(n-1) }}{% else %}.{% endif %}{% endmacro %}{{ double(10) }}`)},
+		"endless.tpl":         &fstest.MapFile{Data: []byte(`{% macro rec() %}{{ rec() }}{% endmacro %}{% for i in items %}{% include "endless_include.tpl" %}{% endfor %}`)},
+		"endless_include.tpl": &fstest.MapFile{Data: []byte(`{% for i in items %}{{ wait() }}{% endfor %}`)},
+	})
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		name    string
+		limits  pongo2.Limits
+		tpl     string
+		context pongo2.Context
+		limit   pongo2.Limit
+		errMsg  string
+	}{
+		{
+			name:    "IncludeDepth",
+			limits:  pongo2.Limits{MaxIncludeDepth: 5},
+			tpl:     "recursive.tpl",
+			context: pongo2.Context{"self": "recursive.tpl"},
+			limit:   pongo2.LimitIncludeDepth,
+			errMsg:  `^\[Error \(where: limit\) in recursive.tpl \| Line 1 Col 4 near 'include'\] resource limit MaxIncludeDepth exceeded \(max is 5\)$`,
+		},
+		{
+			name:    "LoopIterations",
+			limits:  pongo2.Limits{MaxLoopIterations: 50},
+			tpl:     "include.tpl",
+			context: pongo2.Context{"items": items},
+			limit:   pongo2.LimitLoopIterations,
+			errMsg:  `^\[Error \(where: limit\) in loop.tpl \| Line 1 Col 24 near 'for'\] resource limit MaxLoopIterations exceeded \(max is 50\)$`,
+		},
+		{
+			name:    "OutputBytes",
+			limits:  pongo2.Limits{MaxOutputBytes: 10},
+			tpl:     "output.tpl",
+			context: pongo2.Context{"text": "12345"},
+			limit:   pongo2.LimitOutputBytes,
+			errMsg:  `^\[Error \(where: limit\) in output.tpl \| Line 2 Col 4 near 'text'\] resource limit MaxOutputBytes exceeded \(max is 10\)$`,
+		},
+		{
+			// Items not passing the loop condition are iterations as well
+			name:    "FilteredLoopIterations",
+			limits:  pongo2.Limits{MaxLoopIterations: 5},
+			tpl:     "filtered.tpl",
+			context: pongo2.Context{"items": items},
+			limit:   pongo2.LimitLoopIterations,
+			errMsg:  `^\[Error \(where: limit\) in filtered.tpl \| Line 1 Col 4 near 'for'\] resource limit MaxLoopIterations exceeded \(max is 5\)$`,
+		},
+		{
+			// The output of included templates is limited while it's buffered already
+			name:    "IncludedOutputBytes",
+			limits:  pongo2.Limits{MaxOutputBytes: 50},
+			tpl:     "include.tpl",
+			context: pongo2.Context{"items": items},
+			limit:   pongo2.LimitOutputBytes,
+			errMsg:  `^\[Error \(where: limit\) in loop.tpl \| Line 1 Col 41 near '.'\] resource limit MaxOutputBytes exceeded \(max is 50\)$`,
+		},
+		{
+			// The output buffered by macros (and filter, spaceless, ...) is limited while it grows
+			name:   "MacroOutputBytes",
+			limits: pongo2.Limits{MaxOutputBytes: 100},
+			tpl:    "doubling.tpl",
+			limit:  pongo2.LimitOutputBytes,
+			errMsg: `^\[Error \(where: limit\) in doubling.tpl \| Line 1 Col 76 near '.'\] resource limit MaxOutputBytes exceeded \(max is 100\)$`,
+		},
+		{
+			name:   "ExecutionTime",
+			limits: pongo2.Limits{MaxExecutionTime: 20 * time.Millisecond},
+			tpl:    "endless.tpl",
+			context: pongo2.Context{
+				"items": items,
+				"wait":  func() string { time.Sleep(time.Millisecond); return "" },
+			},
+			limit:  pongo2.LimitExecutionTime,
+			errMsg: `^\[Error \(where: limit\) in endless(_include)?.tpl \| Line 1 Col \d+ near '(for|include)'\] resource limit MaxExecutionTime exceeded \(max is 20ms\)$`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := pongo2.NewSet(tt.name, loader)
+			set.Limits = tt.limits
+			tpl, err := set.FromFile(tt.tpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = tpl.ExecuteWriterUnbuffered(tt.context, &buf)
+			if err == nil {
+				t.Fatalf("expected an error, got none (output: %q)", buf.String())
+			}
+			mustEqual(t, err.Error(), tt.errMsg)
+
+			var limitErr *pongo2.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected a *LimitError, got: %#v", err)
+			}
+			if limitErr.Limit != tt.limit {
+				t.Fatalf("expected limit %s, got %s", tt.limit, limitErr.Limit)
+			}
+			if tt.limits.MaxOutputBytes > 0 && int64(buf.Len()) > tt.limits.MaxOutputBytes {
+				t.Fatalf("output exceeds limit: %q", buf.String())
+			}
+
+			// Without limits, the template executes fine (except the infinite ones)
+			if tt.limit == pongo2.LimitLoopIterations || tt.limit == pongo2.LimitOutputBytes {
+				unlimited := pongo2.NewSet(tt.name, loader)
+				tpl, err := unlimited.FromFile(tt.tpl)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tpl.Execute(tt.context); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+
+	// The limits apply to ExecuteBlocks as well
+	set := pongo2.NewSet("blocks-limits", loader)
+	set.Limits = pongo2.Limits{MaxOutputBytes: 50}
+	tpl, err := set.FromString(`{% block a %}{% include "loop.tpl" %}{% endblock %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpl.ExecuteBlocks(pongo2.Context{"items": items}, []string{"a"})
+	var limitErr *pongo2.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != pongo2.LimitOutputBytes {
+		t.Fatalf("expected an output limit error, got: %v", err)
+	}
+}
+
+func TestStrictUndefined(t *testing.T) {
+	set := pongo2.NewSet("strict", &DummyLoader{})
+	set.Options.StrictUndefined = true
+
+	context := pongo2.Context{
+		"user":  &user{Name: "john"},
+		"items": []int{1, 2},
+		"attrs": map[string]any{"title": "Hello", "empty": nil},
+		"none":  nil,
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		errMsg   string
+	}{
+		{template: "{{ user.Name }} {{ items.1 }} {{ attrs.title }} {{ attrs.empty }}{{ none }}", want: "john 2 Hello "},
+		{template: "{{ usr.Name }}", errMsg: `^\[Error \(where: execution\) in <string> \| Line 1 Col 4 near 'usr'\] variable 'usr.Name' is undefined \('usr': unknown name\)$`},
+		{template: "\n  {{ user.Nme|upper }}", errMsg: `^\[Error \(where: execution\) in <string> \| Line 2 Col 6 near 'user'\] variable 'user.Nme' is undefined \('Nme': no such field\)$`},
+		{template: "{{ items.5 }}", errMsg: `.*variable 'items.5' is undefined \('5': index out of range\)$`},
+		{template: "{{ items[5] }}", errMsg: `.*variable 'items.\[subscript\]' is undefined \('\[subscript\]': index out of range\)$`},
+		{template: `{{ attrs.titel }}`, errMsg: `.*variable 'attrs.titel' is undefined \('titel': no such key\)$`},
+		{template: `{{ attrs["titel"] }}`, errMsg: `.*variable 'attrs.\[subscript\]' is undefined \('\[subscript\]': no such key\)$`},
+		{template: `{% for i in itms %}{% endfor %}`, errMsg: `.*variable 'itms' is undefined.*`},
+		{template: `{{ attrs[key] }}`, errMsg: `.*variable 'key' is undefined.*`},
+
+		// Guards
+		{template: `{{ usr.Name|default:"guest" }}`, want: "guest"},
+		{template: `{{ attrs.titel|default_if_none:"none" }}`, want: "none"},
+		{template: `{{ attrs.titel|lower|default:"none" }}`, want: "none"},
+		{template: `{% if usr.Name %}yes{% elif attrs.titel %}yes{% else %}no{% endif %}`, want: "no"},
+		{template: `{% if not usr or items.5 %}guarded{% endif %}`, want: "guarded"},
+		{template: `{% if true %}{{ usr }}{% endif %}`, errMsg: `.*variable 'usr' is undefined.*`},
+		{template: `{{ usr|default:missing }}`, errMsg: `.*variable 'missing' is undefined.*`},
+		// Macros called by guards are executed as usual
+		{template: `{% macro m() %}{{ usr }}{% endmacro %}{% if m() %}{% endif %}`, errMsg: `.*variable 'usr' is undefined.*`},
+		{template: `{% macro m() %}{{ usr }}{% endmacro %}{{ m()|default:"x" }}`, errMsg: `.*variable 'usr' is undefined.*`},
+	}
+	for _, tt := range tests {
+		tpl, err := set.FromString(tt.template)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		out, err := tpl.Execute(context)
+		if tt.errMsg != "" {
+			if err == nil {
+				t.Fatalf("%s: expected an error, got output %q", tt.template, out)
+			}
+			mustEqual(t, err.Error(), tt.errMsg)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		if out != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.template, out, tt.want)
+		}
+	}
+
+	// The default is non-strict
+	out, err := pongo2.NewSet("lenient", &DummyLoader{}).RenderTemplateString("{{ usr.Name }}{{ items.5 }}", context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Fatalf("expected empty output, got %q", out)
+	}
+
+	// Macros called by the operand of the is-operator don't report undefined variables either
+	out, err = pongo2.NewSet("lenient", &DummyLoader{}).RenderTemplateString(
+		"{% macro m() %}{{ usr }}{% endmacro %}{% if m() is defined %}defined{% endif %}", context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "defined" {
+		t.Fatalf("expected 'defined', got %q", out)
+	}
+}
+
+func TestContextFilters(t *testing.T) {
+	set := pongo2.NewSet("context filters", &DummyLoader{})
+	err := set.RegisterContextFilter("greet", func(ctx *pongo2.ExecutionContext, in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		greeting := "Hello"
+		if ctx.Public["locale"] == "de" {
+			greeting = "Hallo"
+		}
+		return pongo2.AsValue(fmt.Sprintf("%s %s%s (%s, autoescape=%t)",
+			greeting, in.String(), param.String(), ctx.TemplateName(), ctx.Autoescape)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl, err := set.FromString(`{{ name|greet:"!" }} {% autoescape off %}{% filter greet %}{{ name }}{% endfilter %}{% endautoescape %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"name": "John", "locale": "de"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^Hallo John! \(&lt;string&gt;, autoescape=true\) Hallo John \(<string>, autoescape=false\)$`)
+
+	// Context filters can be registered globally as well; ApplyFilter() passes no ExecutionContext
+	err = pongo2.RegisterContextFilter("has_context", func(ctx *pongo2.ExecutionContext, in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(ctx != nil), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, perr := pongo2.ApplyFilter("has_context", pongo2.AsValue(nil), nil)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if v.Bool() {
+		t.Fatal("expected no ExecutionContext in ApplyFilter()")
+	}
+	out, err = set.RenderTemplateString("{{ x|has_context }}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^True$")
+}
+
+func TestArgsFilters(t *testing.T) {
+	set := pongo2.NewSet("args filters", &DummyLoader{})
+	err := set.RegisterArgsFilter("wrap", []pongo2.FilterParameter{
+		{Name: "left", Required: true},
+		{Name: "right", Default: "]"},
+	}, func(ctx *pongo2.ExecutionContext, in *pongo2.Value, args *pongo2.FilterArgs) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(fmt.Sprintf("%s%s%s (%t)", args.Get("left"), in, args.Get("right"), args.IsGiven("right"))), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.RegisterArgsFilter("twice", []pongo2.FilterParameter{{Name: "a"}, {Name: "a"}}, nil); err == nil {
+		t.Fatal("expected an error for duplicate parameters")
+	}
+
+	tpl, err := set.FromString(`{{ x|wrap("[") }} {{ x|wrap("<", right=">") }} {{ x|wrap(right=")", left="(") }} {{ x|wrap:"{" }} ` +
+		`{% filter wrap("'", "'")|upper %}{{ x }}{% endfilter %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"x": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^\[a\] \(false\) &lt;a&gt; \(true\) \(a\) \(true\) {a\] \(false\) 'A' \(TRUE\)$`)
+
+	_, err = set.FromString(`{{ x|wrap }}`)
+	if err == nil || !strings.Contains(err.Error(), "Filter 'wrap' requires argument 'left'.") {
+		t.Fatalf("expected missing argument error, got: %v", err)
+	}
+	_, err = set.FromString(`{% filter wrap(foo=1) %}{% endfilter %}`)
+	if err == nil || !strings.Contains(err.Error(), "Filter 'wrap' has no parameter 'foo'.") {
+		t.Fatalf("expected unknown parameter error, got: %v", err)
+	}
+
+	// ApplyFilter() binds the parameter to the first argument
+	v, perr := pongo2.ApplyFilter("truncate", pongo2.AsValue("Hello world"), pongo2.AsValue(8))
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	mustEqual(t, v.String(), `^Hello\.\.\.$`)
+}
+
+func TestConditionalExpressions(t *testing.T) {
+	calls := 0
+	data := pongo2.Context{
+		"fail": func() (string, error) {
+			calls++
+			return "", errors.New("must not be called")
+		},
+		"items": []string{"a", "b"},
+	}
+
+	tpl, err := pongo2.FromString(`{{ "ok" if true else fail() }}{{ fail() if false }}` +
+		`{% with cls="active" if items else "" %}[{{ cls }}]{% endwith %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^ok\[active\]$`)
+	if calls != 0 {
+		t.Fatalf("expected the branches not taken to be skipped, got %d calls", calls)
+	}
+
+	_, err = pongo2.FromString(`{{ "a" if true else }}`)
+	if err == nil {
+		t.Fatal("expected an error for a missing else-expression")
+	}
+}
+
+func TestMapLiterals(t *testing.T) {
+	tpl, err := pongo2.FromString(`{% macro button(label, opts={}) %}<{{ opts.tag|default:"button" }}>{{ label }}</{{ opts.tag|default:"button" }}>{% endmacro %}` +
+		`{{ button("a") }}{{ button("b", {"tag": "span"}) }}{{ {"x": {"y": 1}}|length }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^<button>a</button><span>b</span>1$`)
+
+	for _, s := range []string{`{{ {"a" 1} }}`, `{{ {"a": 1 "b": 2} }}`, `{% with m={"a": 1 %}{% endwith %}`} {
+		if _, err := pongo2.FromString(s); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+}
+
+func TestTests(t *testing.T) {
+	set := pongo2.NewSet("tests", &DummyLoader{})
+	set.Options.StrictUndefined = true
+	err := set.RegisterTest("positive", func(in *pongo2.Value, param *pongo2.Value) (bool, *pongo2.Error) {
+		return in.IsNumber() && in.Float() > 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.RegisterTest("positive", nil); err == nil {
+		t.Fatal("expected an error when registering a test twice")
+	}
+	if pongo2.TestExists("positive") || !set.TestExists("positive") || !set.TestExists("defined") {
+		t.Fatal("expected the test to be registered for the set only")
+	}
+
+	// Undefined variables may be tested even in strict mode
+	tpl, err := set.FromString(`{{ x is positive }} {{ -1 is positive }} {{ missing is defined }} {{ m.missing is undefined }} {{ n is none }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"x": 3, "n": nil, "m": map[string]int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^True False False True True$")
+
+	if _, err := pongo2.FromString(`{{ x is positive }}`); err == nil {
+		t.Fatal("expected set-local tests to be unavailable in other sets")
+	}
+}
+
+type navItem struct {
+	Title    string
+	Children []*navItem
+}
+
+func TestRecursiveLoops(t *testing.T) {
+	tree := []*navItem{
+		{Title: "a", Children: []*navItem{
+			{Title: "a1"},
+			{Title: "a2", Children: []*navItem{{Title: "a2x"}}},
+		}},
+		{Title: "b"},
+	}
+
+	tpl, err := pongo2.FromString(`<ul>{% for item in tree recursive %}<li>{{ item.Title }}@{{ forloop.Depth }}` +
+		`{% if item.Children %}<ul>{{ loop(item.Children) }}</ul>{% endif %}</li>{% endfor %}</ul>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"tree": tree})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^<ul><li>a@1<ul><li>a1@2</li><li>a2@2<ul><li>a2x@3</li></ul></li></ul></li><li>b@1</li></ul>$`)
+
+	// Endless recursion is stopped
+	cyclic := &navItem{Title: "x"}
+	cyclic.Children = []*navItem{cyclic}
+	_, err = tpl.Execute(pongo2.Context{"tree": []*navItem{cyclic}})
+	if err == nil || !strings.Contains(err.Error(), "maximum recursive loop depth reached") {
+		t.Fatalf("expected a recursion error, got: %v", err)
+	}
+}
+
+func TestForLoopUnpacking(t *testing.T) {
+	type score struct {
+		Name   string
+		Points int
+		secret string
+	}
+	data := pongo2.Context{
+		"pairs":   [][2]any{{"a", 1}, {"b", 2}},
+		"structs": []score{{"john", 3, "x"}, {"jane", 5, "y"}},
+		"ptrs":    []*score{{"max", 7, "z"}},
+		"nested":  [][]string{{"x", "y"}, {"z", "w"}},
+		"ragged":  [][]string{{"x", "y"}, {"z"}},
+		"strmap":  map[string]int{"a": 1},
+	}
+
+	out, err := pongo2.RenderTemplateString(`{% for name, n in pairs %}{{ name }}{{ n }} {% endfor %}`+
+		`{% for name, points in structs %}{{ name }}:{{ points }} {% endfor %}`+
+		`{% for name, points in ptrs %}{{ name }}:{{ points }} {% endfor %}`+
+		`{% for a, b in nested %}{{ a }}{{ b }} {% endfor %}`+
+		`{% for item in nested %}{{ item.0 }}{{ item.1 }} {% endfor %}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^a1 b2 john:3 jane:5 max:7 xy zw xy zw $")
+
+	for tpl, msg := range map[string]string{
+		`{% for a, b in ragged %}{% endfor %}`:             `can't unpack 1 values into 2 variables`,
+		`{% for a, b, c in structs %}{% endfor %}`:         `can't unpack 2 values into 3 variables`,
+		`{% for a, b in strmap.a|make_list %}{% endfor %}`: `can't unpack an item of type 'string' into 2 variables`,
+		`{% for k, v, x in strmap %}{% endfor %}`:          `Can't unpack a map's key and value into 3 variables`,
+	} {
+		_, err := pongo2.RenderTemplateString(tpl, data)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected error containing %q, got: %v", tpl, msg, err)
+		}
+	}
+
+	if _, err := pongo2.FromString(`{% for a, a in pairs %}{% endfor %}`); err == nil || !strings.Contains(err.Error(), "Loop variable 'a' is used twice.") {
+		t.Fatalf("expected a parse error for duplicate loop variables, got: %v", err)
+	}
+}
+
+func TestSetNamespace(t *testing.T) {
+	_, err := pongo2.RenderTemplateString(`{% set x = 1 %}{% set x.y = 2 %}`, nil)
+	if err == nil || !strings.Contains(err.Error(), "Can't set attribute 'y' of 'x' since it's not a namespace.") {
+		t.Fatalf("expected an error when setting an attribute of a non-namespace, got: %v", err)
+	}
+
+	// Updates in included templates are visible as well
+	set := pongo2.NewSet("namespaces", pongo2.NewFSLoader(fstest.MapFS{
+		"main.tpl":    &fstest.MapFile{Data: []byte(`{% set ns = namespace(count=0) %}{% for i in items %}{% include "counter.tpl" %}{% endfor %}{{ ns.count }}`)},
+		"counter.tpl": &fstest.MapFile{Data: []byte(`{% set ns.count = ns.count + i %}`)},
+	}))
+	out, err := set.RenderTemplateFile("main.tpl", pongo2.Context{"items": []int{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, "^6$")
+}
+
+type countingLoader struct {
+	pongo2.TemplateLoader
+	gets map[string]int
+}
+
+func (l *countingLoader) Get(path string) (io.Reader, error) {
+	l.gets[path]++
+	return l.TemplateLoader.Get(path)
+}
+
+func TestImportNamespace(t *testing.T) {
+	loader := &countingLoader{
+		TemplateLoader: pongo2.NewFSLoader(fstest.MapFS{
+			"forms.tpl": &fstest.MapFile{Data: []byte(`{% macro input(name, type="text") export %}<input name="{{ name }}" type="{{ type }}">{% endmacro %}`)},
+			"a.tpl":     &fstest.MapFile{Data: []byte(`{% import "forms.tpl" as forms %}{{ forms.input("email", type="email") }}`)},
+			"b.tpl":     &fstest.MapFile{Data: []byte(`{% from "forms.tpl" import * %}{{ input("name") }}`)},
+		}),
+		gets: make(map[string]int),
+	}
+	set := pongo2.NewSet("imports", loader)
+
+	out, err := set.RenderTemplateFile("a.tpl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^<input name="email" type="email">$`)
+
+	tpl, err := set.FromCache("b.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = tpl.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^<input name="name" type="text">$`)
+
+	if _, err := set.FromCache("a.tpl"); err != nil {
+		t.Fatal(err)
+	}
+	if gets := loader.gets["forms.tpl"]; gets != 1 {
+		t.Errorf("the imported template should be compiled once through the cache, but it's been loaded %d times", gets)
+	}
+}
+
+func TestDynamicExtends(t *testing.T) {
+	loader := &countingLoader{
+		TemplateLoader: pongo2.NewFSLoader(fstest.MapFS{
+			"base.tpl":       &fstest.MapFile{Data: []byte(`base[{% block content %}{% endblock %}]`)},
+			"theme/base.tpl": &fstest.MapFile{Data: []byte(`theme[{% block content %}{% endblock %}]`)},
+			"page.tpl":       &fstest.MapFile{Data: []byte(`{% extends layout %}{% block content %}page{% endblock %}`)},
+			"themed.tpl":     &fstest.MapFile{Data: []byte(`{% extends [theme + "/base.tpl", "base.tpl"] %}{% block content %}themed{% endblock %}`)},
+			"ternary.tpl":    &fstest.MapFile{Data: []byte(`{% extends "theme/base.tpl" if dark else "base.tpl" %}{% block content %}ternary{% endblock %}`)},
+			"filtered.tpl":   &fstest.MapFile{Data: []byte(`{% extends "theme/"|add:name %}{% block content %}filtered{% endblock %}`)},
+			"loop.tpl":       &fstest.MapFile{Data: []byte(`{% extends "loop2.tpl" %}`)},
+			"loop2.tpl":      &fstest.MapFile{Data: []byte(`{% extends name %}`)},
+		}),
+		gets: make(map[string]int),
+	}
+	set := pongo2.NewSet("dynamic-extends", loader)
+
+	tests := []struct {
+		name string
+		ctx  pongo2.Context
+		out  string
+	}{
+		{"page.tpl", pongo2.Context{"layout": "base.tpl"}, "^base\\[page\\]$"},
+		{"page.tpl", pongo2.Context{"layout": "theme/base.tpl"}, "^theme\\[page\\]$"},
+		{"themed.tpl", pongo2.Context{"theme": "theme"}, "^theme\\[themed\\]$"},
+		{"themed.tpl", pongo2.Context{"theme": "unknown"}, "^base\\[themed\\]$"},
+		{"ternary.tpl", pongo2.Context{"dark": true}, "^theme\\[ternary\\]$"},
+		{"ternary.tpl", pongo2.Context{"dark": false}, "^base\\[ternary\\]$"},
+		{"filtered.tpl", pongo2.Context{"name": "base.tpl"}, "^theme\\[filtered\\]$"},
+	}
+	for _, test := range tests {
+		tpl, err := set.FromCache(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tpl.Execute(test.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustEqual(t, out, test.out)
+	}
+	if gets := loader.gets["base.tpl"]; gets != 1 {
+		t.Errorf("the parent template should be compiled once through the cache, but it's been loaded %d times", gets)
+	}
+
+	errs := []struct {
+		name string
+		ctx  pongo2.Context
+		err  string
+	}{
+		{"page.tpl", pongo2.Context{"layout": "missing.tpl"}, "Parent template 'missing.tpl' not found."},
+		{"page.tpl", pongo2.Context{"layout": 42}, "must be a string or a list of strings"},
+		{"page.tpl", pongo2.Context{"layout": ""}, "evaluated to an empty string"},
+		{"page.tpl", pongo2.Context{"layout": []string{"a.tpl", "b.tpl"}}, "None of the parent templates ('a.tpl', 'b.tpl') found."},
+		{"page.tpl", pongo2.Context{"layout": []any{"a.tpl", 1}}, "must be a string or a list of strings (got []interface {})"},
+		{"loop.tpl", pongo2.Context{"name": "loop.tpl"}, "Template 'loop.tpl' is extended recursively."},
+	}
+	for _, test := range errs {
+		_, err := set.RenderTemplateFile(test.name, test.ctx)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got: %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestIncludeNotFound(t *testing.T) {
+	loader := &countingLoader{
+		TemplateLoader: pongo2.NewFSLoader(fstest.MapFS{
+			"row.tpl":    &fstest.MapFile{Data: []byte(`row`)},
+			"broken.tpl": &fstest.MapFile{Data: []byte(`{% if %}`)},
+			"nested.tpl": &fstest.MapFile{Data: []byte(`{% include inner %}`)},
+			"list.tpl":   &fstest.MapFile{Data: []byte(`{% include ["custom/row.tpl", "row.tpl"] %}`)},
+		}),
+		gets: make(map[string]int),
+	}
+	set := pongo2.NewSet("include-not-found", loader)
+
+	// The choice of a fallback list is cached
+	for i := 0; i < 3; i++ {
+		out, err := set.RenderTemplateFile("list.tpl", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustEqual(t, out, "^row$")
+	}
+	if gets := loader.gets["custom/row.tpl"]; gets != 1 {
+		t.Errorf("the missing candidate should be looked up once, but it's been looked up %d times", gets)
+	}
+
+	// if_exists only hides a missing template, not one failing to compile or
+	// one including a missing template
+	tpl, err := set.FromString(`[{% include name if_exists %}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"name": "missing.tpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^\[\]$`)
+
+	_, err = tpl.Execute(pongo2.Context{"name": "broken.tpl"})
+	if err == nil || !strings.Contains(err.Error(), "broken.tpl") {
+		t.Errorf("expected a compilation error of broken.tpl, got: %v", err)
+	}
+
+	var notFound *pongo2.TemplateNotFoundError
+	_, err = tpl.Execute(pongo2.Context{"name": "nested.tpl", "inner": "missing.tpl"})
+	if !errors.As(err, &notFound) || notFound.Names[0] != "missing.tpl" {
+		t.Errorf("expected a TemplateNotFoundError for missing.tpl, got: %v", err)
+	}
+
+	// Fallback lists of string templates fall through missing candidates as well
+	// (with a loader resolving the names to absolute paths)
+	local := pongo2.NewSet("include-not-found-local", pongo2.MustNewLocalFileSystemLoader("template_tests"))
+	for _, debug := range []bool{false, true} {
+		local.Debug = debug
+		for _, test := range []struct{ tpl, out string }{
+			{`{% include ["missing.helper", "includes.helper"] with what_am_i="row" %}`, `^I'm row$`},
+			{`[{% include ["missing.helper", "missing2.helper"] if_exists %}]`, `^\[\]$`},
+			{`{% extends ["missing.helper", "required_base.helper"] %}{% block title %}Page{% endblock %}`, `^<title>Page</title>`},
+		} {
+			tpl, err := local.FromString(test.tpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(nil)
+			if err != nil {
+				t.Fatalf("%s (debug: %t): %v", test.tpl, debug, err)
+			}
+			mustEqual(t, out, test.out)
+		}
+	}
+}
+
+func TestComponents(t *testing.T) {
+	loader := &countingLoader{
+		TemplateLoader: pongo2.NewFSLoader(fstest.MapFS{
+			"button.tpl": &fstest.MapFile{Data: []byte(`<button>{% slot %}{% endslot %}{{ secret }}</button>`)},
+			"a.tpl":      &fstest.MapFile{Data: []byte(`{% component "button.tpl" %}A{% endcomponent %}`)},
+			"b.tpl":      &fstest.MapFile{Data: []byte(`{% component name %}{{ secret }}{% endcomponent %}`)},
+		}),
+		gets: make(map[string]int),
+	}
+	set := pongo2.NewSet("components", loader)
+
+	for _, name := range []string{"a.tpl", "b.tpl", "b.tpl"} {
+		tpl, err := set.FromCache(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tpl.Execute(pongo2.Context{"name": "button.tpl", "secret": "S"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The component doesn't see the caller's context, but its slots do
+		mustEqual(t, out, "^<button>[AS]</button>$")
+	}
+	if gets := loader.gets["button.tpl"]; gets != 1 {
+		t.Errorf("the component should be compiled once through the cache, but it's been loaded %d times", gets)
+	}
+}
+
+func TestEmbedCache(t *testing.T) {
+	loader := &countingLoader{
+		TemplateLoader: pongo2.NewFSLoader(fstest.MapFS{
+			"card.tpl": &fstest.MapFile{Data: []byte(`<div>{% block body %}{% endblock %}</div>`)},
+			"a.tpl":    &fstest.MapFile{Data: []byte(`{% embed "card.tpl" %}{% block body %}A{% endblock %}{% endembed %}`)},
+			"b.tpl":    &fstest.MapFile{Data: []byte(`{% embed name %}{% block body %}B{% endblock %}{% endembed %}`)},
+		}),
+		gets: make(map[string]int),
+	}
+	set := pongo2.NewSet("embed-cache", loader)
+
+	for _, name := range []string{"a.tpl", "b.tpl", "b.tpl"} {
+		tpl, err := set.FromCache(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tpl.Execute(pongo2.Context{"name": "card.tpl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustEqual(t, out, "^<div>[AB]</div>$")
+	}
+	if gets := loader.gets["card.tpl"]; gets != 1 {
+		t.Errorf("the embedded template should be compiled once through the cache, but it's been loaded %d times", gets)
+	}
+}
+
+func TestRequiredBlocks(t *testing.T) {
+	set := pongo2.NewSet("required-blocks", pongo2.NewFSLoader(fstest.MapFS{
+		"base.tpl":    &fstest.MapFile{Data: []byte(`{% block title required %}{% endblock %}|{% block content %}base{% endblock %}`)},
+		"layout.tpl":  &fstest.MapFile{Data: []byte(`{% extends "base.tpl" %}{% block content %}[{% block main required %}{% endblock %}]{% endblock %}`)},
+		"page.tpl":    &fstest.MapFile{Data: []byte(`{% extends "layout.tpl" %}{% block title %}Page{% endblock %}{% block main %}main{% endblock %}`)},
+		"partial.tpl": &fstest.MapFile{Data: []byte(`{% extends "layout.tpl" %}{% block title %}Partial{% endblock %}`)},
+		"dynamic.tpl": &fstest.MapFile{Data: []byte(`{% extends layout %}{% block main %}main{% endblock %}`)},
+		"macros.tpl":  &fstest.MapFile{Data: []byte(`{% macro link() export %}{% endmacro %}{% macro button() export %}{% endmacro %}{% macro helper() %}{% endmacro %}`)},
+	}))
+
+	page, err := set.FromFile("page.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := page.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, out, `^Page\|\[main\]$`)
+
+	// Introspection
+	layout := page.Parent()
+	if layout == nil || layout.Parent() == nil || layout.Parent().Parent() != nil {
+		t.Fatal("expected the chain page.tpl -> layout.tpl -> base.tpl")
+	}
+	if blocks := fmt.Sprint(page.Blocks()); blocks != "[main title]" {
+		t.Errorf("unexpected blocks of page.tpl: %s", blocks)
+	}
+	if blocks := fmt.Sprint(layout.Parent().Blocks()); blocks != "[content title]" {
+		t.Errorf("unexpected blocks of base.tpl: %s", blocks)
+	}
+	macros, err := set.FromFile("macros.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := fmt.Sprint(macros.Macros()); names != "[button link]" {
+		t.Errorf("unexpected macros of macros.tpl: %s", names)
+	}
+
+	// A leaf template must override all required blocks of its parents
+	_, err = set.FromFile("partial.tpl")
+	if err == nil || !strings.Contains(err.Error(), "Block 'main' is required but not overridden by 'partial.tpl'.") {
+		t.Errorf("expected a required block error, got: %v", err)
+	}
+
+	// Templates declaring required blocks can be compiled (e. g. as parents), but not executed
+	base, err := set.FromFile("base.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = base.Execute(nil)
+	if err == nil || !strings.Contains(err.Error(), "Block 'title' is required but not overridden by 'base.tpl'.") {
+		t.Errorf("expected a required block error, got: %v", err)
+	}
+	_, err = base.ExecuteBlocks(nil, []string{"content"})
+	if err == nil || !strings.Contains(err.Error(), "Block 'title' is required but not overridden by 'base.tpl'.") {
+		t.Errorf("expected a required block error, got: %v", err)
+	}
+
+	// ExecuteBlocks checks the required blocks against the executed template's
+	// inheritance (and not against the one of the parent defining a block)
+	blocks, err := page.ExecuteBlocks(nil, []string{"title", "content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := blocks["content"]; blocks["title"] != "Page" || !has {
+		t.Errorf("unexpected blocks: %v", blocks)
+	}
+
+	// Dynamic parents are checked during execution
+	dynamic, err := set.FromFile("dynamic.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dynamic.Parent() != nil {
+		t.Error("a dynamic parent should not be returned by Parent()")
+	}
+	_, err = dynamic.Execute(pongo2.Context{"layout": "layout.tpl"})
+	if err == nil || !strings.Contains(err.Error(), "Block 'title' is required but not overridden by 'dynamic.tpl'.") {
+		t.Errorf("expected a required block error, got: %v", err)
+	}
+}
+
+func TestExecuteBlock(t *testing.T) {
+	set := pongo2.NewSet("execute-block", pongo2.NewFSLoader(fstest.MapFS{
+		"base.tpl": &fstest.MapFile{Data: []byte(`<html>{% set title = "Base" %}` +
+			`{% block header %}<h1>{{ title }}</h1>{% endblock %}` +
+			`{% block content %}<ul>{% for item in items %}{% block item %}<li>{{ item }}</li>{% endblock %}{% endfor %}</ul>{% endblock %}` +
+			`{% if false %}{% block hidden %}hidden{% endblock %}{% endif %}` +
+			`{% block footer %}{% include footer %}{% endblock %}</html>`)},
+		"page.tpl": &fstest.MapFile{Data: []byte(`{% extends "base.tpl" %}` +
+			`{% block header %}[{{ block.Super }}]{% endblock %}` +
+			`{% block item %}<li class="page">{{ item }}</li>{% endblock %}`)},
+		"footer.tpl": &fstest.MapFile{Data: []byte(`footer`)},
+	}))
+
+	page, err := set.FromFile("page.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := pongo2.Context{"items": []string{"a", "b"}, "footer": "footer.tpl"}
+
+	tests := []struct {
+		block string
+		out   string
+	}{
+		// Overridden block calling the parent's block (which uses a variable set before the block)
+		{"header", `^\[<h1>Base</h1>\]$`},
+		// Nested and overridden block
+		{"content", `^<ul><li class="page">a</li><li class="page">b</li></ul>$`},
+		// Only the first rendering of a nested block within a loop
+		{"item", `^<li class="page">a</li>$`},
+		// The block is defined, but not reached
+		{"hidden", `^$`},
+		{"footer", `^footer$`},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := page.ExecuteBlock(test.block, ctx, &buf); err != nil {
+			t.Fatalf("%s: %v", test.block, err)
+		}
+		mustEqual(t, buf.String(), test.out)
+	}
+
+	// The execution stops after the block (the footer would fail)
+	var header bytes.Buffer
+	if err := page.ExecuteBlock("header", pongo2.Context{"footer": "missing.tpl"}, &header); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = page.ExecuteBlock("missing", ctx, &buf)
+	if err == nil || !strings.Contains(err.Error(), "Block 'missing' not found.") {
+		t.Errorf("expected a block not found error, got: %v", err)
+	}
+	err = page.ExecuteBlock("footer", pongo2.Context{"footer": "missing.tpl"}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "missing.tpl") {
+		t.Errorf("expected an execution error of the footer, got: %v", err)
+	}
+
+	// ExecuteBlocks finds the blocks of the parents as well
+	blocks, err := page.ExecuteBlocks(ctx, []string{"hidden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustEqual(t, blocks["hidden"], "^hidden$")
+}
+
+func TestRevalidateCache(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := fstest.MapFS{
+		"base.tpl":   &fstest.MapFile{Data: []byte(`base[{% block content %}{% endblock %}]`), ModTime: modTime},
+		"page.tpl":   &fstest.MapFile{Data: []byte(`{% extends "base.tpl" %}{% block content %}{% import "macros.tpl" m %}{{ m() }}{% include "row.tpl" %}{% endblock %}`), ModTime: modTime},
+		"macros.tpl": &fstest.MapFile{Data: []byte(`{% macro m() export %}m1{% endmacro %}`), ModTime: modTime},
+		// No modification time; the content's hash is used instead
+		"row.tpl": &fstest.MapFile{Data: []byte(`row1`)},
+	}
+	set := pongo2.NewSet("revalidate-cache", pongo2.NewFSLoader(files))
+	set.RevalidateCache = true
+	set.RevalidateInterval = time.Nanosecond
+
+	render := func(name, expected string) *pongo2.Template {
+		t.Helper()
+		tpl, err := set.FromCache(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tpl.Execute(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustEqual(t, out, expected)
+		return tpl
+	}
+
+	tpl := render("page.tpl", `^base\[m1row1\]$`)
+	if render("page.tpl", `^base\[m1row1\]$`) != tpl {
+		t.Error("an unchanged template should stay cached")
+	}
+
+	// Changes of the template itself and of any of its (nested) dependencies are picked up
+	files["base.tpl"] = &fstest.MapFile{Data: []byte(`base2[{% block content %}{% endblock %}]`), ModTime: modTime.Add(time.Second)}
+	tpl = render("page.tpl", `^base2\[m1row1\]$`)
+	files["macros.tpl"] = &fstest.MapFile{Data: []byte(`{% macro m() export %}m2{% endmacro %}`), ModTime: modTime.Add(time.Second)}
+	render("page.tpl", `^base2\[m2row1\]$`)
+	files["row.tpl"] = &fstest.MapFile{Data: []byte(`row2`)}
+	render("page.tpl", `^base2\[m2row2\]$`)
+	files["page.tpl"] = &fstest.MapFile{Data: []byte(`page`), ModTime: modTime.Add(time.Second)}
+	tpl = render("page.tpl", `^page$`)
+	if render("page.tpl", `^page$`) != tpl {
+		t.Error("an unchanged template should stay cached")
+	}
+
+	// A template added in front of the chosen one of a fallback list is used
+	files["list.tpl"] = &fstest.MapFile{Data: []byte(`{% include ["custom/row.tpl", "row.tpl"] %}`), ModTime: modTime}
+	render("list.tpl", `^row2$`)
+	files["custom/row.tpl"] = &fstest.MapFile{Data: []byte(`custom`), ModTime: modTime}
+	render("list.tpl", `^custom$`)
+
+	// Templates aren't checked again within the revalidation interval
+	set.RevalidateInterval = time.Hour
+	render("page.tpl", `^page$`)
+	files["page.tpl"] = &fstest.MapFile{Data: []byte(`page2`), ModTime: modTime.Add(2 * time.Second)}
+	render("page.tpl", `^page$`)
+	set.RevalidateInterval = time.Nanosecond
+	render("page.tpl", `^page2$`)
+
+	// A removed template results in an error
+	delete(files, "page.tpl")
+	if _, err := set.FromCache("page.tpl"); !errors.As(err, new(*pongo2.TemplateNotFoundError)) {
+		t.Errorf("expected a TemplateNotFoundError, got: %v", err)
+	}
+
+	// Without revalidation, templates are cached until the cache is cleaned
+	files["other.tpl"] = &fstest.MapFile{Data: []byte(`other1`), ModTime: modTime}
+	set.RevalidateCache = false
+	render("other.tpl", `^other1$`)
+	files["other.tpl"] = &fstest.MapFile{Data: []byte(`other2`), ModTime: modTime.Add(time.Second)}
+	render("other.tpl", `^other1$`)
+}
@@ -9,35 +9,63 @@ type tagBlockNode struct {
 	name string
 }
 
-func (node *tagBlockNode) getBlockWrappers(tpl *Template) []*NodeWrapper {
+// getBlockWrappers returns the block's wrappers of all templates in the
+// inheritance chain, starting with the base template.
+func (node *tagBlockNode) getBlockWrappers(ctx *ExecutionContext) []*NodeWrapper {
 	nodeWrappers := make([]*NodeWrapper, 0)
-	var t *NodeWrapper
 
-	for tpl != nil {
-		t = tpl.blocks[node.name]
-		if t != nil {
+	for i := len(ctx.inheritance) - 1; i >= 0; i-- {
+		if t := ctx.inheritance[i].blocks[node.name]; t != nil {
 			nodeWrappers = append(nodeWrappers, t)
 		}
-		tpl = tpl.child
 	}
 
 	return nodeWrappers
 }
 
-func (node *tagBlockNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	tpl := ctx.template
-	if tpl == nil {
-		panic("internal error: tpl == nil")
+// blockFragment describes the single block rendered by Template.ExecuteBlock.
+type blockFragment struct {
+	name      string
+	writer    TemplateWriter // the writer receiving the block's output
+	rendering bool
+	done      bool
+}
+
+// defined reports whether any template of the inheritance chain defines the block.
+func (f *blockFragment) defined(ctx *ExecutionContext) bool {
+	for _, t := range ctx.inheritance {
+		if _, has := t.blocks[f.name]; has {
+			return true
+		}
 	}
+	return false
+}
 
+func (node *tagBlockNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	// Determine the block to execute
-	blockWrappers := node.getBlockWrappers(tpl)
+	blockWrappers := node.getBlockWrappers(ctx)
 	lenBlockWrappers := len(blockWrappers)
 
 	if lenBlockWrappers == 0 {
 		return ctx.Error("internal error: len(block_wrappers) == 0 in tagBlockNode.Execute()", nil)
 	}
 
+	if f := ctx.fragment; f != nil && !f.rendering && f.name == node.name {
+		// This is the block to render; the execution is stopped afterwards
+		f.rendering = true
+		if err := node.executeWrappers(ctx, f.writer, blockWrappers); err != nil {
+			return err
+		}
+		f.done = true
+		return ctx.Error(fmt.Sprintf("Execution stopped after rendering block '%s'.", node.name), nil)
+	}
+
+	return node.executeWrappers(ctx, writer, blockWrappers)
+}
+
+func (node *tagBlockNode) executeWrappers(ctx *ExecutionContext, writer TemplateWriter, blockWrappers []*NodeWrapper) *Error {
+	lenBlockWrappers := len(blockWrappers)
+
 	blockWrapper := blockWrappers[lenBlockWrappers-1]
 	ctx.Private["block"] = tagBlockInformation{
 		ctx:      ctx,
@@ -71,13 +99,70 @@ func (t tagBlockInformation) Super() (*Value, error) {
 
 	blockWrapper := t.wrappers[lenWrappers-1]
 	buf := bytes.NewBufferString("")
-	err := blockWrapper.Execute(superCtx, &templateWriter{buf})
+	err := blockWrapper.Execute(superCtx, superCtx.limits.writer(&templateWriter{buf}, true))
+	superCtx.limits.releasePending(buf.Len())
 	if err != nil {
 		return AsSafeValue(""), err
 	}
 	return AsSafeValue(buf.String()), nil
 }
 
+// staticInheritance returns the inheritance chain of tpl (starting with tpl
+// itself). ok is false if the chain contains a dynamic parent which is only known
+// during execution.
+func (tpl *Template) staticInheritance() (chain []*Template, ok bool) {
+	for t := tpl; t != nil; t = t.parent {
+		chain = append(chain, t)
+		if t.extends != nil && t.parent == nil {
+			return nil, false
+		}
+	}
+	return chain, true
+}
+
+// checkRequiredBlocks checks the required blocks of tpl's inheritance chain if
+// tpl extends another template; required blocks of a template which isn't
+// extending any other template are only checked once it gets executed (so it
+// can still be compiled as a parent).
+func (tpl *Template) checkRequiredBlocks() *Error {
+	chain, ok := tpl.staticInheritance()
+	if !ok || len(chain) < 2 {
+		return nil
+	}
+	return checkRequiredBlocks(chain, "parser")
+}
+
+// checkRequiredBlocks returns an error if a required block of a template of the
+// inheritance chain (starting with the leaf) isn't overridden by any of its children.
+func checkRequiredBlocks(chain []*Template, sender string) *Error {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if len(chain[i].requiredBlocks) == 0 {
+			continue
+		}
+
+	nextBlock:
+		for _, name := range sortedNames(chain[i].requiredBlocks) {
+			for _, child := range chain[:i] {
+				if _, has := child.blocks[name]; has && child.requiredBlocks[name] == nil {
+					continue nextBlock
+				}
+			}
+
+			token := chain[i].requiredBlocks[name]
+			return &Error{
+				Template:  chain[i],
+				Filename:  token.Filename,
+				Line:      token.Line,
+				Column:    token.Col,
+				Token:     token,
+				Sender:    sender,
+				OrigError: fmt.Errorf("Block '%s' is required but not overridden by '%s'.", name, chain[0].name),
+			}
+		}
+	}
+	return nil
+}
+
 func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	if arguments.Count() == 0 {
 		return nil, arguments.Error("Tag 'block' requires an identifier.", nil)
@@ -88,8 +173,11 @@ func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, arguments.Error("First argument for tag 'block' must be an identifier.", nil)
 	}
 
+	// A required block must be overridden by a child template
+	required := arguments.Match(TokenIdentifier, "required") != nil
+
 	if arguments.Remaining() != 0 {
-		return nil, arguments.Error("Tag 'block' takes exactly 1 argument (an identifier).", nil)
+		return nil, arguments.Error("Tag 'block' takes exactly 1 argument (an identifier) and the optional 'required' flag.", nil)
 	}
 
 	wrapper, endtagargs, err := doc.WrapUntilTag("endblock")
@@ -120,6 +208,9 @@ func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 	} else {
 		return nil, arguments.Error(fmt.Sprintf("Block named '%s' already defined", nameToken.Val), nil)
 	}
+	if required {
+		tpl.requiredBlocks[nameToken.Val] = nameToken
+	}
 
 	return &tagBlockNode{name: nameToken.Val}, nil
 }
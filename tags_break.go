@@ -0,0 +1,23 @@
+package pongo2
+
+type tagBreakNode struct {
+	position *Token
+}
+
+func (node *tagBreakNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	return ctx.OrigError(errLoopBreak, node.position)
+}
+
+func tagBreakParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	if doc.loopDepth == 0 {
+		return nil, arguments.Error("'break' is only allowed inside a for-loop.", start)
+	}
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'break' does not take any argument.", nil)
+	}
+	return &tagBreakNode{position: start}, nil
+}
+
+func init() {
+	RegisterTag("break", tagBreakParser)
+}
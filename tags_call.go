@@ -0,0 +1,91 @@
+package pongo2
+
+// macroCaller is passed by a call block as the last argument to the called
+// macro which makes it available as 'caller' inside the macro body.
+type macroCaller func(args ...*Value) (*Value, error)
+
+type tagCallNode struct {
+	position *Token
+	output   *nodeVariable
+
+	// caller is an anonymous macro which renders the body of the call block
+	caller *tagMacroNode
+}
+
+func (node *tagCallNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	return node.output.Execute(ctx, writer)
+}
+
+// callerEval is appended to the arguments of the called macro; the caller's body
+// gets executed within the context of the call block.
+type callerEval struct {
+	node *tagCallNode
+}
+
+func (c callerEval) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	return AsValue(macroCaller(func(args ...*Value) (*Value, error) {
+		return c.node.caller.call(ctx, args...)
+	})), nil
+}
+
+func tagCallParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	callNode := &tagCallNode{
+		position: start,
+		caller: &tagMacroNode{
+			position: start,
+			name:     "caller",
+			args:     make(map[string]IEvaluator),
+		},
+	}
+
+	// Arguments the macro passes to caller(), e. g. {% call(item) list(items) %}
+	if arguments.Match(TokenSymbol, "(") != nil {
+		if err := parseMacroArguments(arguments, callNode.caller); err != nil {
+			return nil, err
+		}
+	}
+
+	macroToken := arguments.PeekType(TokenIdentifier)
+	if macroToken == nil {
+		return nil, arguments.Error("Expected a macro call.", nil)
+	}
+	call, err := arguments.parseVariableOrLiteral()
+	if err != nil {
+		return nil, err
+	}
+	resolver, isResolver := call.(*variableResolver)
+	if !isResolver || !resolver.parts[len(resolver.parts)-1].isFunctionCall {
+		return nil, arguments.Error("Expected a macro call.", macroToken)
+	}
+	lastPart := resolver.parts[len(resolver.parts)-1]
+	lastPart.callingArgs = append(lastPart.callingArgs, callerEval{node: callNode})
+
+	callNode.output = &nodeVariable{
+		locationToken: macroToken,
+		expr:          resolver,
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed call-tag arguments.", nil)
+	}
+
+	// Body wrapping; like a macro body, it's not part of any enclosing for-loop
+	loopDepth := doc.loopDepth
+	doc.loopDepth = 0
+	wrapper, endargs, err := doc.WrapUntilTag("endcall")
+	doc.loopDepth = loopDepth
+	if err != nil {
+		return nil, err
+	}
+	callNode.caller.wrapper = wrapper
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return callNode, nil
+}
+
+func init() {
+	RegisterTag("call", tagCallParser)
+}
@@ -0,0 +1,159 @@
+package pongo2
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tagComponentNode struct {
+	position          *Token
+	tpl               *Template
+	filenameEvaluator IEvaluator
+	withPairs         map[string]IEvaluator
+
+	// The content for the component's slots (the default slot's content is
+	// everything outside of named slots)
+	slots map[string]*NodeWrapper
+}
+
+func (node *tagComponentNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if err := ctx.checkCanceled(node.position); err != nil {
+		return err
+	}
+	if err := ctx.checkIncludeDepth(node.position); err != nil {
+		return err
+	}
+
+	// Components have an isolated context: they only see the given pairs
+	componentCtx := make(Context)
+	for key, value := range node.withPairs {
+		val, err := value.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		componentCtx[key] = val
+	}
+
+	tpl := node.tpl
+	if tpl == nil {
+		filename, err := node.filenameEvaluator.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		if !filename.IsString() || filename.String() == "" {
+			return ctx.Error("Filename for 'component'-tag must evaluate to a non-empty string.", node.position)
+		}
+
+		tpl, err = componentTemplate(ctx.template, filename.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	parent, includeCtx, err := tpl.newIncludedContext(ctx, componentCtx, nil)
+	if err != nil {
+		return err
+	}
+	includeCtx.slots = make(map[string]*componentSlot, len(node.slots))
+	for name, wrapper := range node.slots {
+		includeCtx.slots[name] = &componentSlot{
+			ctx:     ctx,
+			wrapper: wrapper,
+		}
+	}
+	return parent.executeIncludedRoot(includeCtx, writer)
+}
+
+// componentTemplate compiles a component's template (only once through the set's cache).
+func componentTemplate(tpl *Template, filename string) (*Template, *Error) {
+	componentTpl, err := tpl.set.FromCache(tpl.set.resolveFilename(tpl, filename))
+	if err != nil {
+		return nil, err.(*Error)
+	}
+	return componentTpl, nil
+}
+
+func tagComponentParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	componentNode := &tagComponentNode{
+		position:  start,
+		withPairs: make(map[string]IEvaluator),
+		slots:     make(map[string]*NodeWrapper),
+	}
+
+	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
+		tpl, err := componentTemplate(doc.template, filenameToken.Val)
+		if err != nil {
+			return nil, err.updateFromTokenIfNeeded(doc.template, filenameToken)
+		}
+		componentNode.tpl = tpl
+		doc.template.addDependency(tpl)
+	} else {
+		// The component is determined at execution time
+		filenameEvaluator, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		componentNode.filenameEvaluator = filenameEvaluator
+	}
+
+	// key=expr pairs (the component's context)
+	for arguments.Remaining() > 0 {
+		keyToken := arguments.MatchType(TokenIdentifier)
+		if keyToken == nil {
+			return nil, arguments.Error("Expected an identifier", nil)
+		}
+		if arguments.Match(TokenSymbol, "=") == nil {
+			return nil, arguments.Error("Expected '='.", nil)
+		}
+		valueExpr, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		componentNode.withPairs[keyToken.Val] = valueExpr
+	}
+
+	// Body wrapping; the body (and its slots) is rendered as part of the component,
+	// so it's not part of any enclosing for-loop
+	loopDepth := doc.loopDepth
+	doc.loopDepth = 0
+	wrapper, endargs, err := doc.WrapUntilTag("endcomponent")
+	doc.loopDepth = loopDepth
+	if err != nil {
+		return nil, err
+	}
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	// Split up the content into the named slots and the default slot
+	defaultSlot := &NodeWrapper{}
+	hasDefaultContent := false
+	for idx, n := range wrapper.nodes {
+		if slot, isSlot := n.(*tagSlotNode); isSlot {
+			if _, has := componentNode.slots[slot.name]; has {
+				return nil, doc.Error(fmt.Sprintf("Slot '%s' is filled more than once.", slot.name), slot.position)
+			}
+			componentNode.slots[slot.name] = slot.wrapper
+			continue
+		}
+
+		if html, isHTML := n.(*nodeHTML); !isHTML || strings.TrimSpace(html.token.Val) != "" {
+			hasDefaultContent = true
+		}
+		defaultSlot.nodes = append(defaultSlot.nodes, n)
+		defaultSlot.positions = append(defaultSlot.positions, wrapper.positions[idx])
+	}
+	if hasDefaultContent {
+		if _, has := componentNode.slots[defaultSlotName]; has {
+			return nil, doc.Error(fmt.Sprintf("Slot '%s' is filled more than once (content outside of slots fills it as well).",
+				defaultSlotName), start)
+		}
+		componentNode.slots[defaultSlotName] = defaultSlot
+	}
+
+	return componentNode, nil
+}
+
+func init() {
+	RegisterTag("component", tagComponentParser)
+}
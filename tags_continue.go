@@ -0,0 +1,23 @@
+package pongo2
+
+type tagContinueNode struct {
+	position *Token
+}
+
+func (node *tagContinueNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	return ctx.OrigError(errLoopContinue, node.position)
+}
+
+func tagContinueParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	if doc.loopDepth == 0 {
+		return nil, arguments.Error("'continue' is only allowed inside a for-loop.", start)
+	}
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'continue' does not take any argument.", nil)
+	}
+	return &tagContinueNode{position: start}, nil
+}
+
+func init() {
+	RegisterTag("continue", tagContinueParser)
+}
@@ -0,0 +1,140 @@
+package pongo2
+
+import (
+	"strings"
+)
+
+type tagEmbedNode struct {
+	position          *Token
+	tpl               *Template
+	filenameEvaluator IEvaluator
+	only              bool
+	withPairs         map[string]IEvaluator
+
+	// overrides holds the blocks defined within the embed-tag; it acts as an
+	// anonymous child template of the embedded template.
+	overrides *Template
+}
+
+func (node *tagEmbedNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if err := ctx.checkCanceled(node.position); err != nil {
+		return err
+	}
+	if err := ctx.checkIncludeDepth(node.position); err != nil {
+		return err
+	}
+
+	embedCtx, err := newIncludeContext(ctx, node.withPairs, node.only)
+	if err != nil {
+		return err
+	}
+
+	tpl := node.tpl
+	if tpl == nil {
+		filename, err := node.filenameEvaluator.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		if !filename.IsString() || filename.String() == "" {
+			return ctx.Error("Filename for 'embed'-tag must evaluate to a non-empty string.", node.position)
+		}
+
+		// Compiled only once through the set's cache (like components)
+		embeddedTpl, err2 := ctx.template.set.fromCache(ctx.template.set.resolveFilename(ctx.template, filename.String()))
+		if err2 != nil {
+			return err2.(*Error)
+		}
+		tpl = embeddedTpl
+	}
+
+	// The overridden blocks take precedence over the ones of the embedded
+	// template (and its parents), just like those of a child template.
+	parent, includeCtx, err := tpl.newIncludedContext(ctx, embedCtx, node.overrides)
+	if err != nil {
+		return err
+	}
+
+	return parent.executeIncludedRoot(includeCtx, writer)
+}
+
+func tagEmbedParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	embedNode := &tagEmbedNode{
+		position:  start,
+		withPairs: make(map[string]IEvaluator),
+		overrides: &Template{
+			set:            doc.template.set,
+			name:           doc.template.name,
+			blocks:         make(map[string]*NodeWrapper),
+			requiredBlocks: make(map[string]*Token),
+		},
+	}
+
+	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
+		// prepared, static template
+		embeddedFilename := doc.template.set.resolveFilename(doc.template, filenameToken.Val)
+		embeddedTpl, err := doc.template.set.fromCache(embeddedFilename)
+		if err != nil {
+			return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, filenameToken)
+		}
+		embedNode.tpl = embeddedTpl
+		doc.template.addDependency(embeddedTpl)
+	} else {
+		// No string, then the user wants to use lazy-evaluation
+		filenameEvaluator, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		embedNode.filenameEvaluator = filenameEvaluator
+	}
+
+	only, err := parseWithPairs(doc, arguments, embedNode.withPairs)
+	if err != nil {
+		return nil, err
+	}
+	embedNode.only = only
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed 'embed'-tag arguments.", nil)
+	}
+
+	// The blocks within the embed-tag are registered as the overrides instead
+	// of being blocks of the template containing the embed-tag. They are rendered
+	// as part of the embedded template, so they're not part of any enclosing for-loop.
+	blocks, requiredBlocks, loopDepth := doc.template.blocks, doc.template.requiredBlocks, doc.loopDepth
+	doc.template.blocks, doc.template.requiredBlocks = embedNode.overrides.blocks, embedNode.overrides.requiredBlocks
+	doc.loopDepth = 0
+	wrapper, endargs, err := doc.WrapUntilTag("endembed")
+	doc.template.blocks, doc.template.requiredBlocks, doc.loopDepth = blocks, requiredBlocks, loopDepth
+	if err != nil {
+		return nil, err
+	}
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	for _, n := range wrapper.nodes {
+		if _, isBlock := n.(*tagBlockNode); isBlock {
+			continue
+		}
+		if html, isHTML := n.(*nodeHTML); !isHTML || strings.TrimSpace(html.token.Val) != "" {
+			return nil, doc.Error("Only blocks are allowed within 'embed'.", start)
+		}
+	}
+
+	// The required blocks of a static embedded template (and its static parents)
+	// must be overridden within the embed-tag
+	if embedNode.tpl != nil {
+		if chain, ok := embedNode.tpl.staticInheritance(); ok {
+			chain = append([]*Template{embedNode.overrides}, chain...)
+			if err := checkRequiredBlocks(chain, "parser"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return embedNode, nil
+}
+
+func init() {
+	RegisterTag("embed", tagEmbedParser)
+}
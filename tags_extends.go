@@ -1,49 +1,135 @@
 package pongo2
 
+import (
+	"fmt"
+	"strings"
+)
+
 type tagExtendsNode struct {
+	position *Token
 	filename string
+
+	// Set if the parent is determined at execution time, e. g. {% extends layout %}
+	// or {% extends ["theme/base.html", "base.html"] %}
+	parentEvaluator IEvaluator
 }
 
 func (node *tagExtendsNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	return nil
 }
 
+// templateFilenames returns the template name(s) of a string or a list of
+// strings (a fallback list); ok is false for any other value.
+func templateFilenames(value *Value) (filenames []string, ok bool) {
+	if value.IsString() {
+		return []string{value.String()}, true
+	}
+	if !value.CanSlice() {
+		return nil, false
+	}
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i)
+		if v, isValue := item.Interface().(*Value); isValue {
+			// Items of list literals
+			item = v
+		}
+		if !item.IsString() {
+			return nil, false
+		}
+		filenames = append(filenames, item.String())
+	}
+	return filenames, true
+}
+
+// resolveParent returns the template tpl extends (or nil if it doesn't extend any).
+// Dynamic parents are evaluated within ctx and compiled through the set's cache;
+// if a list of filenames is given, the first one which can be loaded is used.
+func (tpl *Template) resolveParent(ctx *ExecutionContext) (*Template, *Error) {
+	node := tpl.extends
+	if node == nil || node.parentEvaluator == nil {
+		return tpl.parent, nil
+	}
+
+	value, err := node.parentEvaluator.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filenames, ok := templateFilenames(value)
+	if !ok {
+		return nil, ctx.Error(fmt.Sprintf("Parent template for 'extends'-tag must be a string or a list of strings (got %T).",
+			value.Interface()), node.position)
+	}
+
+	for _, filename := range filenames {
+		if filename == "" {
+			return nil, ctx.Error("Parent template name for 'extends'-tag evaluated to an empty string.", node.position)
+		}
+	}
+
+	parent, found, err2 := tpl.set.fromCacheFirstFound(tpl, filenames)
+	if err2 != nil {
+		return nil, err2.(*Error)
+	}
+	if found {
+		return parent, nil
+	}
+
+	if len(filenames) == 1 {
+		return nil, ctx.Error(fmt.Sprintf("Parent template '%s' not found.", filenames[0]), node.position)
+	}
+	return nil, ctx.Error(fmt.Sprintf("None of the parent templates ('%s') found.", strings.Join(filenames, "', '")), node.position)
+}
+
 func tagExtendsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	extendsNode := &tagExtendsNode{}
+	extendsNode := &tagExtendsNode{
+		position: start,
+	}
 
 	if doc.template.level > 1 {
 		return nil, arguments.Error("The 'extends' tag can only defined on root level.", start)
 	}
 
-	if doc.template.parent != nil {
+	if doc.template.extends != nil {
 		// Already one parent
 		return nil, arguments.Error("This template has already one parent.", start)
 	}
 
-	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
+	if filenameToken := arguments.PeekType(TokenString); filenameToken != nil && arguments.Remaining() == 1 {
 		// prepared, static template
+		arguments.Consume()
 
 		// Get parent's filename
 		parentFilename := doc.template.set.resolveFilename(doc.template, filenameToken.Val)
 
 		// Parse the parent
-		parentTemplate, err := doc.template.set.FromFile(parentFilename)
+		parentTemplate, err := doc.template.set.fromFile(parentFilename)
 		if err != nil {
 			return nil, err.(*Error)
 		}
 
 		// Keep track of things
-		parentTemplate.child = doc.template
 		doc.template.parent = parentTemplate
+		doc.template.addDependency(parentTemplate)
 		extendsNode.filename = parentFilename
 	} else {
-		return nil, arguments.Error("Tag 'extends' requires a template filename as string.", nil)
+		// No single string, then the parent is determined at execution time (e. g. a
+		// variable, a list of candidates or an expression like "base_"|add:theme)
+		if arguments.Remaining() == 0 {
+			return nil, arguments.Error("Tag 'extends' requires a template filename.", nil)
+		}
+		parentEvaluator, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		extendsNode.parentEvaluator = parentEvaluator
 	}
 
 	if arguments.Remaining() > 0 {
 		return nil, arguments.Error("Tag 'extends' does only take 1 argument.", nil)
 	}
 
+	doc.template.extends = extendsNode
 	return extendsNode, nil
 }
 
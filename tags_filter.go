@@ -2,48 +2,38 @@ package pongo2
 
 import (
 	"bytes"
+	"fmt"
 )
 
-type nodeFilterCall struct {
-	name      string
-	paramExpr IEvaluator
-}
-
 type tagFilterNode struct {
 	position    *Token
 	bodyWrapper *NodeWrapper
-	filterChain []*nodeFilterCall
+	filterChain []*filterCall
 }
 
 func (node *tagFilterNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	temp := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB size
 
-	err := node.bodyWrapper.Execute(ctx, temp)
-	if err != nil {
-		return err
+	// The output rendered before a break/continue is filtered as well
+	bodyErr := node.bodyWrapper.Execute(ctx, ctx.limits.writer(temp, true))
+	ctx.limits.releasePending(temp.Len())
+	if bodyErr != nil && !isLoopControl(bodyErr) {
+		return bodyErr
 	}
 
 	value := AsValue(temp.String())
 
 	for _, call := range node.filterChain {
-		var param *Value
-		if call.paramExpr != nil {
-			param, err = call.paramExpr.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-		} else {
-			param = AsValue(nil)
-		}
-		value, err = ApplyFilter(call.name, value, param)
+		var err *Error
+		value, err = call.Execute(value, ctx)
 		if err != nil {
-			return ctx.Error(err.Error(), node.position)
+			return err
 		}
 	}
 
 	writer.WriteString(value.String())
 
-	return nil
+	return bodyErr
 }
 
 func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
@@ -58,25 +48,23 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 	filterNode.bodyWrapper = wrapper
 
 	for arguments.Remaining() > 0 {
-		filterCall := &nodeFilterCall{}
-
-		nameToken := arguments.MatchType(TokenIdentifier)
-		if nameToken == nil {
+		if arguments.PeekType(TokenIdentifier) == nil {
 			return nil, arguments.Error("Expected a filter name (identifier).", nil)
 		}
-		filterCall.name = nameToken.Val
-
-		if arguments.MatchOne(TokenSymbol, ":") != nil {
-			// Filter parameter
-			// NOTICE: we can't use ParseExpression() here, because it would parse the next filter "|..." as well in the argument list
-			expr, err := arguments.parseVariableOrLiteral()
-			if err != nil {
-				return nil, err
-			}
-			filterCall.paramExpr = expr
+
+		// NOTICE: parseFilter() doesn't use ParseExpression() for the colon form,
+		// because it would parse the next filter "|..." as well in the argument list
+		call, err := arguments.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+
+		// Check sandbox filter restriction
+		if _, isBanned := doc.template.set.bannedFilters[call.name]; isBanned {
+			return nil, arguments.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", call.name), nil)
 		}
 
-		filterNode.filterChain = append(filterNode.filterChain, filterCall)
+		filterNode.filterChain = append(filterNode.filterChain, call)
 
 		if arguments.MatchOne(TokenSymbol, "|") == nil {
 			break
@@ -1,11 +1,33 @@
 package pongo2
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// The errors returned by {% break %} and {% continue %}; they are passed
+// up to the enclosing for-loop like any other execution error.
+var (
+	errLoopBreak    = errors.New("'break' outside of a for-loop")
+	errLoopContinue = errors.New("'continue' outside of a for-loop")
+)
+
+const maxRecursiveLoopDepth = 1000
+
 type tagForNode struct {
-	key             string
-	value           string // only for maps: for key, value in map
+	position *Token
+
+	// names of the loop variables; for maps: key [, value]; for other
+	// iterables: item or the names the items are unpacked into (for a, b in pairs)
+	names []string
+
 	objectEvaluator IEvaluator
+	condition       IEvaluator // optional: for x in items if condition
 	reversed        bool
 	sorted          bool
+	recursive       bool
 
 	bodyWrapper  *NodeWrapper
 	emptyWrapper *NodeWrapper
@@ -18,17 +40,47 @@ type tagForLoopInformation struct {
 	Revcounter0 int
 	First       bool
 	Last        bool
+	Length      int
+	Depth       int // only for recursive loops: the recursion level, starting with 1
+	Depth0      int
+	Previtem    any // the item of the previous iteration (nil on the first one)
+	Nextitem    any // the item of the next iteration (nil on the last one)
 	Parentloop  *tagForLoopInformation
 }
 
-func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (forError *Error) {
+// Cycle returns one of the given values depending on the current iteration:
+//
+//	{{ forloop.Cycle("odd", "even") }}
+func (info *tagForLoopInformation) Cycle(values ...*Value) *Value {
+	if len(values) == 0 {
+		return AsValue(nil)
+	}
+	return values[info.Counter0%len(values)]
+}
+
+// isLoopControl reports whether err has been raised by the break- or continue-tag.
+// Tags buffering their body must write out the output rendered so far before
+// passing such an error on to the loop.
+func isLoopControl(err *Error) bool {
+	return errors.Is(err, errLoopBreak) || errors.Is(err, errLoopContinue)
+}
+
+func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	return node.execute(ctx, nil, 1, writer)
+}
+
+// execute runs the loop. obj is nil for the loop itself (objectEvaluator will
+// be evaluated) and given for the recursive calls using loop(items).
+func (node *tagForNode) execute(ctx *ExecutionContext, obj *Value, depth int, writer TemplateWriter) (forError *Error) {
 	// Backup forloop (as parentloop in public context), key-name and value-name
 	forCtx := NewChildExecutionContext(ctx)
 	parentloop := forCtx.Private["forloop"]
 
 	// Create loop struct
 	loopInfo := &tagForLoopInformation{
-		First: true,
+		First:  true,
+		Depth:  depth,
+		Depth0: depth - 1,
 	}
 
 	// Is it a loop in a loop?
@@ -39,80 +91,303 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 	// Register loopInfo in public context
 	forCtx.Private["forloop"] = loopInfo
 
-	obj, err := node.objectEvaluator.Evaluate(forCtx)
-	if err != nil {
-		return err
+	if obj == nil {
+		var err *Error
+		obj, err = node.objectEvaluator.Evaluate(forCtx)
+		if err != nil {
+			return err
+		}
 	}
 
-	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
-		// There's something to iterate over (correct type and at least 1 item)
+	if node.recursive {
+		forCtx.Private["loop"] = func(items *Value) (*Value, error) {
+			if depth >= maxRecursiveLoopDepth {
+				return nil, forCtx.Error(fmt.Sprintf("maximum recursive loop depth reached (max is %v)", maxRecursiveLoopDepth), node.position)
+			}
+			if items == nil {
+				items = AsValue(nil)
+			}
+			var b bytes.Buffer
+			err := node.execute(forCtx, items, depth+1, forCtx.limits.writer(&b, true))
+			forCtx.limits.releasePending(b.Len())
+			if err != nil {
+				return AsSafeValue(""), err
+			}
+			return AsSafeValue(b.String()), nil
+		}
+	}
+
+	if node.condition != nil {
+		return node.executeFiltered(forCtx, loopInfo, obj, writer)
+	}
 
-		// Update loop infos and public context
-		forCtx.Private[node.key] = key
-		if value != nil {
-			forCtx.Private[node.value] = value
+	// The body of an item is executed once the next item is known (for
+	// forloop.Nextitem), so the items don't need to be collected first.
+	var prev, current *loopItem
+	var total int
+	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
+		total = count
+		next := &loopItem{key: key, value: value}
+		if current != nil {
+			forError = node.executeBody(forCtx, loopInfo, idx-1, count, prev, current, next, true, writer)
+			if forError != nil {
+				return false
+			}
 		}
-		loopInfo.Counter = idx + 1
-		loopInfo.Counter0 = idx
-		if idx == 1 {
-			loopInfo.First = false
+		prev, current = current, next
+		return true
+	}, func() {
+		// Nothing to iterate over (maybe wrong type or no items)
+		if node.emptyWrapper != nil {
+			forError = node.emptyWrapper.Execute(forCtx, writer)
 		}
-		if idx+1 == count {
-			loopInfo.Last = true
+	}, node.reversed, node.sorted)
+	if forError == nil && current != nil {
+		forError = node.executeBody(forCtx, loopInfo, total-1, total, prev, current, nil, true, writer)
+	}
+	if forError != nil && errors.Is(forError, errLoopBreak) {
+		return nil
+	}
+	return forError
+}
+
+// executeFiltered runs a loop with a condition (for x in items if condition). The
+// items passing the condition are collected first, as the loop information (like
+// Length or Last) only takes them into account.
+func (node *tagForNode) executeFiltered(forCtx *ExecutionContext, loopInfo *tagForLoopInformation, obj *Value, writer TemplateWriter) (forError *Error) {
+	var items []*loopItem
+	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
+		// Evaluating the condition is an iteration as well
+		if err := forCtx.checkCanceled(node.position); err != nil {
+			forError = err
+			return false
+		}
+		if err := forCtx.countLoopIteration(node.position); err != nil {
+			forError = err
+			return false
 		}
-		loopInfo.Revcounter = count - idx        // TODO: Not sure about this, have to look it up
-		loopInfo.Revcounter0 = count - (idx + 1) // TODO: Not sure about this, have to look it up
 
-		// Render elements with updated context
-		err := node.bodyWrapper.Execute(forCtx, writer)
+		if err := node.bindItem(forCtx, key, value); err != nil {
+			forError = err
+			return false
+		}
+		// Like in the if-tag, the condition is allowed to check for
+		// undefined variables (see Options.StrictUndefined)
+		result, err := node.condition.Evaluate(forCtx.allowUndefined())
 		if err != nil {
 			forError = err
 			return false
 		}
+		if result.IsTrue() {
+			items = append(items, &loopItem{key: key, value: value})
+		}
 		return true
-	}, func() {
+	}, func() {}, node.reversed, node.sorted)
+	if forError != nil {
+		return forError
+	}
+
+	if len(items) == 0 {
 		// Nothing to iterate over (maybe wrong type or no items)
 		if node.emptyWrapper != nil {
-			err := node.emptyWrapper.Execute(forCtx, writer)
-			if err != nil {
-				forError = err
+			return node.emptyWrapper.Execute(forCtx, writer)
+		}
+		return nil
+	}
+
+	count := len(items)
+	for idx, item := range items {
+		var prev, next *loopItem
+		if idx > 0 {
+			prev = items[idx-1]
+		}
+		if idx+1 < count {
+			next = items[idx+1]
+		}
+		// The iteration has been counted while evaluating the condition already
+		if err := node.executeBody(forCtx, loopInfo, idx, count, prev, item, next, false, writer); err != nil {
+			if errors.Is(err, errLoopBreak) {
+				return nil
 			}
+			return err
 		}
-	}, node.reversed, node.sorted)
+	}
+	return nil
+}
 
-	return forError
+// loopItem is an item of the iterated object; value is only given for maps.
+type loopItem struct {
+	key, value *Value
+}
+
+// executeBody executes the loop's body for the item at idx; prev and next are the
+// neighbouring items (nil for the first/last one). A continue-tag within the body
+// is handled here, a break-tag's error is returned.
+func (node *tagForNode) executeBody(forCtx *ExecutionContext, loopInfo *tagForLoopInformation, idx, count int, prev, item, next *loopItem, countIteration bool, writer TemplateWriter) *Error {
+	// Stop iterating as soon as the execution has been canceled
+	if err := forCtx.checkCanceled(node.position); err != nil {
+		return err
+	}
+	if countIteration {
+		if err := forCtx.countLoopIteration(node.position); err != nil {
+			return err
+		}
+	}
+
+	// Update loop infos and public context
+	if err := node.bindItem(forCtx, item.key, item.value); err != nil {
+		return err
+	}
+	loopInfo.Counter = idx + 1
+	loopInfo.Counter0 = idx
+	loopInfo.First = idx == 0
+	loopInfo.Last = idx+1 == count
+	loopInfo.Length = count
+	loopInfo.Revcounter = count - idx        // TODO: Not sure about this, have to look it up
+	loopInfo.Revcounter0 = count - (idx + 1) // TODO: Not sure about this, have to look it up
+	loopInfo.Previtem = node.itemOf(prev)
+	loopInfo.Nextitem = node.itemOf(next)
+
+	// Render elements with updated context
+	err := node.bodyWrapper.Execute(forCtx, writer)
+	if err != nil && !errors.Is(err, errLoopContinue) {
+		return err
+	}
+	return nil
+}
+
+// itemOf returns the item as seen by forloop.Previtem and forloop.Nextitem: the
+// value when iterating over a map's keys and values, otherwise the key or the
+// (not unpacked) item.
+func (node *tagForNode) itemOf(item *loopItem) any {
+	if item == nil {
+		return nil
+	}
+	if item.value != nil && len(node.names) == 2 {
+		return item.value.Interface()
+	}
+	return item.key.Interface()
+}
+
+// bindItem puts the current item into the loop's context. value is only
+// given when iterating over maps.
+func (node *tagForNode) bindItem(forCtx *ExecutionContext, key, value *Value) *Error {
+	if value != nil {
+		// for key, value in map
+		if len(node.names) > 2 {
+			return forCtx.Error(fmt.Sprintf("Can't unpack a map's key and value into %d variables.", len(node.names)), node.position)
+		}
+		forCtx.Private[node.names[0]] = key
+		if len(node.names) == 2 {
+			forCtx.Private[node.names[1]] = value
+		}
+		return nil
+	}
+
+	if len(node.names) == 1 {
+		forCtx.Private[node.names[0]] = key
+		return nil
+	}
+
+	// for a, b in items: unpack the item
+	items, err := unpackLoopItem(key, len(node.names))
+	if err != nil {
+		return forCtx.OrigError(err, node.position)
+	}
+	for idx, name := range node.names {
+		forCtx.Private[name] = items[idx]
+	}
+	return nil
+}
+
+// unpackLoopItem splits a slice/array (by index) or a struct (by the order of
+// its exported fields) into exactly n values.
+func unpackLoopItem(item *Value, n int) ([]*Value, error) {
+	// Items of in-template array definitions are *Values already
+	if v, isValue := item.Interface().(*Value); isValue {
+		item = v
+	}
+	rv := item.getResolvedValue()
+	if rv.Kind() == reflect.Interface {
+		rv = reflect.ValueOf(rv.Interface())
+	}
+
+	var fields []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			fields = append(fields, rv.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).IsExported() {
+				fields = append(fields, rv.Field(i))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("can't unpack an item of type '%s' into %d variables", rv.Kind(), n)
+	}
+
+	if len(fields) != n {
+		return nil, fmt.Errorf("can't unpack %d values into %d variables", len(fields), n)
+	}
+
+	values := make([]*Value, 0, n)
+	for _, field := range fields {
+		// Items of in-template array definitions are *Values already
+		if v, isValue := field.Interface().(*Value); isValue {
+			values = append(values, v)
+			continue
+		}
+		values = append(values, AsValue(field.Interface()))
+	}
+	return values, nil
 }
 
 func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	forNode := &tagForNode{}
+	forNode := &tagForNode{
+		position: start,
+	}
 
 	// Arguments parsing
-	var valueToken *Token
 	keyToken := arguments.MatchType(TokenIdentifier)
 	if keyToken == nil {
 		return nil, arguments.Error("Expected an key identifier as first argument for 'for'-tag", nil)
 	}
+	forNode.names = append(forNode.names, keyToken.Val)
 
-	if arguments.Match(TokenSymbol, ",") != nil {
-		// Value name is provided
-		valueToken = arguments.MatchType(TokenIdentifier)
+	for arguments.Match(TokenSymbol, ",") != nil {
+		// Value name(s) provided
+		valueToken := arguments.MatchType(TokenIdentifier)
 		if valueToken == nil {
 			return nil, arguments.Error("Value name must be an identifier.", nil)
 		}
+		for _, name := range forNode.names {
+			if name == valueToken.Val {
+				return nil, arguments.Error(fmt.Sprintf("Loop variable '%s' is used twice.", name), valueToken)
+			}
+		}
+		forNode.names = append(forNode.names, valueToken.Val)
 	}
 
 	if arguments.Match(TokenKeyword, "in") == nil {
 		return nil, arguments.Error("Expected keyword 'in'.", nil)
 	}
 
-	objectEvaluator, err := arguments.ParseExpression()
+	// The object can't be an inline if (without parentheses), because the
+	// 'if' introduces the loop condition
+	objectEvaluator, err := arguments.parseOrExpression()
 	if err != nil {
 		return nil, err
 	}
 	forNode.objectEvaluator = objectEvaluator
-	forNode.key = keyToken.Val
-	if valueToken != nil {
-		forNode.value = valueToken.Val
+
+	if arguments.Match(TokenIdentifier, "if") != nil {
+		condition, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		forNode.condition = condition
 	}
 
 	if arguments.MatchOne(TokenIdentifier, "reversed") != nil {
@@ -123,12 +398,18 @@ func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 		forNode.sorted = true
 	}
 
+	if arguments.MatchOne(TokenIdentifier, "recursive") != nil {
+		forNode.recursive = true
+	}
+
 	if arguments.Remaining() > 0 {
 		return nil, arguments.Error("Malformed for-loop arguments.", nil)
 	}
 
 	// Body wrapping
+	doc.loopDepth++
 	wrapper, endargs, err := doc.WrapUntilTag("empty", "endfor")
+	doc.loopDepth--
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,49 @@
+package pongo2
+
+// tagFromParser parses {% from "file" import * %} and {% from "file" import name, name as alias %}.
+func tagFromParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	importNode := &tagImportNode{
+		position: start,
+		macros:   make(map[string]*tagMacroNode),
+	}
+
+	filenameToken := arguments.MatchType(TokenString)
+	if filenameToken == nil {
+		return nil, arguments.Error("From-tag needs a filename as string.", nil)
+	}
+
+	if arguments.Match(TokenIdentifier, "import") == nil {
+		return nil, arguments.Error("Expected 'import' keyword.", nil)
+	}
+
+	if arguments.Remaining() == 0 {
+		return nil, arguments.Error("You must at least specify one macro to import.", nil)
+	}
+
+	// Compile the given template
+	filename, tpl, err := importTemplate(doc, start, filenameToken)
+	if err != nil {
+		return nil, err
+	}
+	importNode.filename = filename
+
+	if starToken := arguments.Match(TokenSymbol, "*"); starToken != nil {
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed from-tag arguments.", nil)
+		}
+		if err := importAll(doc, arguments, importNode, tpl, starToken); err != nil {
+			return nil, err
+		}
+		return importNode, nil
+	}
+
+	if err := parseImportList(doc, arguments, importNode, tpl); err != nil {
+		return nil, err
+	}
+
+	return importNode, nil
+}
+
+func init() {
+	RegisterTag("from", tagFromParser)
+}
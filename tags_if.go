@@ -6,8 +6,10 @@ type tagIfNode struct {
 }
 
 func (node *tagIfNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// Conditions are allowed to check for undefined variables (see Options.StrictUndefined)
+	condCtx := ctx.allowUndefined()
 	for i, condition := range node.conditions {
-		result, err := condition.Evaluate(ctx)
+		result, err := condition.Evaluate(condCtx)
 		if err != nil {
 			return err
 		}
@@ -17,8 +17,9 @@ func (node *tagIfchangedNode) Execute(ctx *ExecutionContext, writer TemplateWrit
 		// Check against own rendered body
 
 		buf := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
-		err := node.thenWrapper.Execute(ctx, buf)
-		if err != nil {
+		err := node.thenWrapper.Execute(ctx, ctx.limits.writer(buf, true))
+		ctx.limits.releasePending(buf.Len())
+		if err != nil && !isLoopControl(err) {
 			return err
 		}
 
@@ -28,6 +29,10 @@ func (node *tagIfchangedNode) Execute(ctx *ExecutionContext, writer TemplateWrit
 			writer.Write(bufBytes)
 			node.lastContent = bufBytes
 		}
+		if err != nil {
+			// Pass the break/continue on (after writing the output rendered so far)
+			return err
+		}
 	} else {
 		nowValues := make([]*Value, 0, len(node.watchedExpr))
 		for _, expr := range node.watchedExpr {
@@ -2,69 +2,93 @@ package pongo2
 
 import (
 	"fmt"
+	"sort"
 )
 
 type tagImportNode struct {
-	position *Token
-	filename string
-	macros   map[string]*tagMacroNode // alias/name -> macro instance
+	position  *Token
+	filename  string
+	macros    map[string]*tagMacroNode // alias/name -> macro instance
+	namespace string                   // set if all macros are imported as namespace ({% import "file" as ns %})
 }
 
 func (node *tagImportNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	var namespace map[string]any
+	if node.namespace != "" {
+		namespace = make(map[string]any, len(node.macros))
+		ctx.Private[node.namespace] = namespace
+	}
+
 	for name, macro := range node.macros {
 		func(name string, macro *tagMacroNode) {
-			ctx.Private[name] = func(args ...*Value) (*Value, error) {
+			fn := macroFunction(func(args ...*Value) (*Value, error) {
 				return macro.call(ctx, args...)
+			})
+			if namespace != nil {
+				namespace[name] = fn
+			} else {
+				ctx.Private[name] = fn
 			}
 		}(name, macro)
 	}
 	return nil
 }
 
-func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	importNode := &tagImportNode{
-		position: start,
-		macros:   make(map[string]*tagMacroNode),
-	}
+// importTemplate compiles the template to import macros from (only once through
+// the set's cache).
+func importTemplate(doc *Parser, start *Token, filenameToken *Token) (string, *Template, *Error) {
+	filename := doc.template.set.resolveFilename(doc.template, filenameToken.Val)
 
-	filenameToken := arguments.MatchType(TokenString)
-	if filenameToken == nil {
-		return nil, arguments.Error("Import-tag needs a filename as string.", nil)
+	tpl, err := doc.template.set.fromCache(filename)
+	if err != nil {
+		return "", nil, err.(*Error).updateFromTokenIfNeeded(doc.template, start)
 	}
+	doc.template.addDependency(tpl)
+	return filename, tpl, nil
+}
 
-	importNode.filename = doc.template.set.resolveFilename(doc.template, filenameToken.Val)
-
-	if arguments.Remaining() == 0 {
-		return nil, arguments.Error("You must at least specify one macro to import.", nil)
+// registerImportedName reports a name clash of an imported macro or namespace
+// with another import or a macro defined by the importing template.
+func registerImportedName(doc *Parser, arguments *Parser, name, filename string, token *Token) *Error {
+	if otherFilename, has := doc.template.importedNames[name]; has {
+		return arguments.Error(fmt.Sprintf("Name '%s' is already imported from '%s'.", name, otherFilename), token)
 	}
-
-	// Compile the given template
-	tpl, err := doc.template.set.FromFile(importNode.filename)
-	if err != nil {
-		return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, start)
+	if doc.template.definedMacros[name] {
+		return arguments.Error(fmt.Sprintf("Name '%s' clashes with the macro '%s'.", name, name), token)
 	}
+	doc.template.importedNames[name] = filename
+	return nil
+}
 
+// parseImportList parses a comma-separated list of macro names (with optional
+// aliases) to import from tpl.
+func parseImportList(doc *Parser, arguments *Parser, importNode *tagImportNode, tpl *Template) *Error {
 	for arguments.Remaining() > 0 {
 		macroNameToken := arguments.MatchType(TokenIdentifier)
 		if macroNameToken == nil {
-			return nil, arguments.Error("Expected macro name (identifier).", nil)
+			return arguments.Error("Expected macro name (identifier).", nil)
 		}
 
 		asName := macroNameToken.Val
+		asToken := macroNameToken
 		if arguments.Match(TokenKeyword, "as") != nil {
 			aliasToken := arguments.MatchType(TokenIdentifier)
 			if aliasToken == nil {
-				return nil, arguments.Error("Expected macro alias name (identifier).", nil)
+				return arguments.Error("Expected macro alias name (identifier).", nil)
 			}
 			asName = aliasToken.Val
+			asToken = aliasToken
 		}
 
 		macroInstance, has := tpl.exportedMacros[macroNameToken.Val]
 		if !has {
-			return nil, arguments.Error(fmt.Sprintf("Macro '%s' not found (or not exported) in '%s'.", macroNameToken.Val,
+			return arguments.Error(fmt.Sprintf("Macro '%s' not found (or not exported) in '%s'.", macroNameToken.Val,
 				importNode.filename), macroNameToken)
 		}
 
+		if err := registerImportedName(doc, arguments, asName, importNode.filename, asToken); err != nil {
+			return err
+		}
 		importNode.macros[asName] = macroInstance
 
 		if arguments.Remaining() == 0 {
@@ -72,8 +96,72 @@ func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 		}
 
 		if arguments.Match(TokenSymbol, ",") == nil {
-			return nil, arguments.Error("Expected ','.", nil)
+			return arguments.Error("Expected ','.", nil)
+		}
+	}
+	return nil
+}
+
+// importAll imports all exported macros of tpl.
+func importAll(doc *Parser, arguments *Parser, importNode *tagImportNode, tpl *Template, token *Token) *Error {
+	names := make([]string, 0, len(tpl.exportedMacros))
+	for name := range tpl.exportedMacros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := registerImportedName(doc, arguments, name, importNode.filename, token); err != nil {
+			return err
 		}
+		importNode.macros[name] = tpl.exportedMacros[name]
+	}
+	return nil
+}
+
+func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	importNode := &tagImportNode{
+		position: start,
+		macros:   make(map[string]*tagMacroNode),
+	}
+
+	filenameToken := arguments.MatchType(TokenString)
+	if filenameToken == nil {
+		return nil, arguments.Error("Import-tag needs a filename as string.", nil)
+	}
+
+	if arguments.Remaining() == 0 {
+		return nil, arguments.Error("You must at least specify one macro to import.", nil)
+	}
+
+	// Compile the given template
+	filename, tpl, err := importTemplate(doc, start, filenameToken)
+	if err != nil {
+		return nil, err
+	}
+	importNode.filename = filename
+
+	// Import all macros as namespace
+	if arguments.Match(TokenKeyword, "as") != nil {
+		namespaceToken := arguments.MatchType(TokenIdentifier)
+		if namespaceToken == nil {
+			return nil, arguments.Error("Expected namespace name (identifier).", nil)
+		}
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed import-tag arguments.", nil)
+		}
+		if err := registerImportedName(doc, arguments, namespaceToken.Val, filename, namespaceToken); err != nil {
+			return nil, err
+		}
+		importNode.namespace = namespaceToken.Val
+		for name, macro := range tpl.exportedMacros {
+			importNode.macros[name] = macro
+		}
+		return importNode, nil
+	}
+
+	if err := parseImportList(doc, arguments, importNode, tpl); err != nil {
+		return nil, err
 	}
 
 	return importNode, nil
@@ -1,6 +1,11 @@
 package pongo2
 
+import (
+	"fmt"
+)
+
 type tagIncludeNode struct {
+	position          *Token
 	tpl               *Template
 	filenameEvaluator IEvaluator
 	lazy              bool
@@ -11,59 +16,69 @@ type tagIncludeNode struct {
 }
 
 func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	// Building the context for the template
-	includeCtx := make(Context)
-
-	// Fill the context with all data from the parent
-	if !node.only {
-		includeCtx.Update(ctx.Public)
-		includeCtx.Update(ctx.Private)
+	if err := ctx.checkCanceled(node.position); err != nil {
+		return err
+	}
+	if err := ctx.checkIncludeDepth(node.position); err != nil {
+		return err
 	}
 
-	// Put all custom with-pairs into the context
-	for key, value := range node.withPairs {
-		val, err := value.Evaluate(ctx)
-		if err != nil {
-			return err
-		}
-		includeCtx[key] = val
+	// Building the context for the template
+	includeCtx, err := newIncludeContext(ctx, node.withPairs, node.only)
+	if err != nil {
+		return err
 	}
 
 	// Execute the template
 	if node.lazy {
-		// Evaluate the filename
+		// Evaluate the filename (or a list of candidates)
 		filename, err := node.filenameEvaluator.Evaluate(ctx)
 		if err != nil {
 			return err
 		}
 
-		if filename.String() == "" {
-			return ctx.Error("Filename for 'include'-tag evaluated to an empty string.", nil)
+		filenames, ok := templateFilenames(filename)
+		if !ok {
+			return ctx.Error(fmt.Sprintf("Filename for 'include'-tag must be a string or a list of strings (got %T).",
+				filename.Interface()), nil)
+		}
+		for _, filename := range filenames {
+			if filename == "" {
+				return ctx.Error("Filename for 'include'-tag evaluated to an empty string.", nil)
+			}
+		}
+
+		if len(filenames) > 1 {
+			// Include the first template found
+			includedTpl, found, err2 := ctx.template.set.fromCacheFirstFound(ctx.template, filenames)
+			if err2 != nil {
+				return err2.(*Error)
+			}
+			if !found {
+				if node.ifExists {
+					return nil
+				}
+				return ctx.OrigError(&TemplateNotFoundError{Names: filenames}, node.position)
+			}
+			return includedTpl.executeIncluded(ctx, includeCtx, writer)
 		}
 
 		// Get include-filename
-		includedFilename := ctx.template.set.resolveFilename(ctx.template, filename.String())
+		includedFilename := ctx.template.set.resolveFilename(ctx.template, filenames[0])
 
 		includedTpl, err2 := ctx.template.set.FromFile(includedFilename)
 		if err2 != nil {
-			// if this is ReadFile error, and "if_exists" flag is enabled
-			if node.ifExists && err2.(*Error).Sender == "fromfile" {
+			// A missing template is fine if the "if_exists" flag is enabled (but not
+			// a template which fails to compile)
+			if node.ifExists && isTemplateNotFound(err2, includedFilename) {
 				return nil
 			}
 			return err2.(*Error)
 		}
-		err2 = includedTpl.ExecuteWriter(includeCtx, writer)
-		if err2 != nil {
-			return err2.(*Error)
-		}
-		return nil
+		return includedTpl.executeIncluded(ctx, includeCtx, writer)
 	}
 	// Template is already parsed with static filename
-	err := node.tpl.ExecuteWriter(includeCtx, writer)
-	if err != nil {
-		return err.(*Error)
-	}
-	return nil
+	return node.tpl.executeIncluded(ctx, includeCtx, writer)
 }
 
 type tagIncludeEmptyNode struct{}
@@ -72,8 +87,62 @@ func (node *tagIncludeEmptyNode) Execute(ctx *ExecutionContext, writer TemplateW
 	return nil
 }
 
+// newIncludeContext builds the context for an included template: all data of
+// the including template (unless only is set) plus the with-pairs.
+func newIncludeContext(ctx *ExecutionContext, withPairs map[string]IEvaluator, only bool) (Context, *Error) {
+	includeCtx := make(Context)
+
+	// Fill the context with all data from the parent
+	if !only {
+		includeCtx.Update(ctx.Public)
+		includeCtx.Update(ctx.Private)
+	}
+
+	// Put all custom with-pairs into the context
+	for key, value := range withPairs {
+		val, err := value.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		includeCtx[key] = val
+	}
+
+	return includeCtx, nil
+}
+
+// parseWithPairs parses the options "with key=expr ... [only]" into withPairs.
+func parseWithPairs(doc *Parser, arguments *Parser, withPairs map[string]IEvaluator) (only bool, err *Error) {
+	if arguments.Match(TokenIdentifier, "with") == nil {
+		return false, nil
+	}
+
+	for arguments.Remaining() > 0 {
+		// We have at least one key=expr pair (because of starting "with")
+		keyToken := arguments.MatchType(TokenIdentifier)
+		if keyToken == nil {
+			return false, arguments.Error("Expected an identifier", nil)
+		}
+		if arguments.Match(TokenSymbol, "=") == nil {
+			return false, arguments.Error("Expected '='.", nil)
+		}
+		valueExpr, err := arguments.ParseExpression()
+		if err != nil {
+			return false, err.updateFromTokenIfNeeded(doc.template, keyToken)
+		}
+
+		withPairs[keyToken.Val] = valueExpr
+
+		// Only?
+		if arguments.Match(TokenIdentifier, "only") != nil {
+			return true, nil // stop parsing arguments because it's the last option
+		}
+	}
+	return false, nil
+}
+
 func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	includeNode := &tagIncludeNode{
+		position:  start,
 		withPairs: make(map[string]IEvaluator),
 	}
 
@@ -90,13 +159,14 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 		includeNode.filename = includedFilename
 		includedTpl, err := doc.template.set.FromFile(includedFilename)
 		if err != nil {
-			// if this is ReadFile error, and "if_exists" token presents we should create and empty node
-			if err.(*Error).Sender == "fromfile" && ifExists {
+			// if the template doesn't exist and "if_exists" token presents we should create and empty node
+			if ifExists && isTemplateNotFound(err, includedFilename) {
 				return &tagIncludeEmptyNode{}, nil
 			}
 			return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, filenameToken)
 		}
 		includeNode.tpl = includedTpl
+		doc.template.addDependency(includedTpl)
 	} else {
 		// No String, then the user wants to use lazy-evaluation (slower, but possible)
 		filenameEvaluator, err := arguments.ParseExpression()
@@ -109,30 +179,11 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 	}
 
 	// After having parsed the filename we're gonna parse the with+only options
-	if arguments.Match(TokenIdentifier, "with") != nil {
-		for arguments.Remaining() > 0 {
-			// We have at least one key=expr pair (because of starting "with")
-			keyToken := arguments.MatchType(TokenIdentifier)
-			if keyToken == nil {
-				return nil, arguments.Error("Expected an identifier", nil)
-			}
-			if arguments.Match(TokenSymbol, "=") == nil {
-				return nil, arguments.Error("Expected '='.", nil)
-			}
-			valueExpr, err := arguments.ParseExpression()
-			if err != nil {
-				return nil, err.updateFromTokenIfNeeded(doc.template, keyToken)
-			}
-
-			includeNode.withPairs[keyToken.Val] = valueExpr
-
-			// Only?
-			if arguments.Match(TokenIdentifier, "only") != nil {
-				includeNode.only = true
-				break // stop parsing arguments because it's the last option
-			}
-		}
+	only, err := parseWithPairs(doc, arguments, includeNode.withPairs)
+	if err != nil {
+		return nil, err
 	}
+	includeNode.only = only
 
 	if arguments.Remaining() > 0 {
 		return nil, arguments.Error("Malformed 'include'-tag arguments.", nil)
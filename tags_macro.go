@@ -12,13 +12,15 @@ type tagMacroNode struct {
 	name      string
 	argsOrder []string
 	args      map[string]IEvaluator
+	varargs   string // name of the catch-all for extra positional arguments (*varargs)
+	kwargs    string // name of the catch-all for unknown keyword arguments (**kwargs)
 	exported  bool
 
 	wrapper *NodeWrapper
 }
 
 func (node *tagMacroNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	ctx.Private[node.name] = func(args ...*Value) (*Value, error) {
+	ctx.Private[node.name] = macroFunction(func(args ...*Value) (*Value, error) {
 		ctx.macroDepth++
 		defer func() {
 			ctx.macroDepth--
@@ -29,12 +31,59 @@ func (node *tagMacroNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		}
 
 		return node.call(ctx, args...)
-	}
+	})
 
 	return nil
 }
 
+// macroFunction is the function a macro is callable as within templates.
+type macroFunction func(args ...*Value) (*Value, error)
+
+// macroKwargs holds the keyword arguments of a macro call; it's passed as
+// the last argument (before a call block's caller) to the macro.
+type macroKwargs struct {
+	names  []string
+	values []*Value
+}
+
+type macroKwargsEval struct {
+	names []string
+	exprs []IEvaluator
+}
+
+func (e *macroKwargsEval) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	kwargs := &macroKwargs{names: e.names}
+	for _, expr := range e.exprs {
+		value, err := expr.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		kwargs.values = append(kwargs.values, value)
+	}
+	return AsValue(kwargs), nil
+}
+
 func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, error) {
+	if err := ctx.checkCanceled(node.position); err != nil {
+		return AsSafeValue(""), err
+	}
+
+	// A call block passes its body as the last argument
+	var caller macroCaller
+	if len(args) > 0 {
+		if c, isCaller := args[len(args)-1].Interface().(macroCaller); isCaller {
+			caller = c
+			args = args[:len(args)-1]
+		}
+	}
+	var kwargs *macroKwargs
+	if len(args) > 0 {
+		if kw, isKwargs := args[len(args)-1].Interface().(*macroKwargs); isKwargs {
+			kwargs = kw
+			args = args[:len(args)-1]
+		}
+	}
+
 	argsCtx := make(Context)
 
 	for k, v := range node.args {
@@ -53,12 +102,53 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 		}
 	}
 
+	var varargs []any
 	if len(args) > len(node.argsOrder) {
-		// Too many arguments, we're ignoring them and just logging into debug mode.
-		err := ctx.Error(fmt.Sprintf("Macro '%s' called with too many arguments (%d instead of %d).",
-			node.name, len(args), len(node.argsOrder)), nil).updateFromTokenIfNeeded(ctx.template, node.position)
+		if node.varargs == "" {
+			err := ctx.Error(fmt.Sprintf("Macro '%s' called with too many arguments (%d instead of %d).",
+				node.name, len(args), len(node.argsOrder)), nil).updateFromTokenIfNeeded(ctx.template, node.position)
 
-		return AsSafeValue(""), err
+			return AsSafeValue(""), err
+		}
+		for _, argValue := range args[len(node.argsOrder):] {
+			varargs = append(varargs, argValue.Interface())
+		}
+		args = args[:len(node.argsOrder)]
+	}
+
+	for idx, argValue := range args {
+		argsCtx[node.argsOrder[idx]] = argValue.Interface()
+	}
+
+	extraKwargs := make(map[string]any)
+	if kwargs != nil {
+		for idx, name := range kwargs.names {
+			_, isArg := node.args[name]
+			switch {
+			case isArg:
+				for _, argName := range node.argsOrder[:len(args)] {
+					if argName == name {
+						err := ctx.Error(fmt.Sprintf("Macro '%s' got multiple values for argument '%s'.",
+							node.name, name), nil).updateFromTokenIfNeeded(ctx.template, node.position)
+						return AsSafeValue(""), err
+					}
+				}
+				argsCtx[name] = kwargs.values[idx].Interface()
+			case node.kwargs != "":
+				extraKwargs[name] = kwargs.values[idx].Interface()
+			default:
+				err := ctx.Error(fmt.Sprintf("Macro '%s' has no argument '%s'.",
+					node.name, name), nil).updateFromTokenIfNeeded(ctx.template, node.position)
+				return AsSafeValue(""), err
+			}
+		}
+	}
+
+	if node.varargs != "" {
+		argsCtx[node.varargs] = varargs
+	}
+	if node.kwargs != "" {
+		argsCtx[node.kwargs] = extraKwargs
 	}
 
 	// Make a context for the macro execution
@@ -67,12 +157,13 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 	// Register all arguments in the private context
 	macroCtx.Private.Update(argsCtx)
 
-	for idx, argValue := range args {
-		macroCtx.Private[node.argsOrder[idx]] = argValue.Interface()
+	if caller != nil {
+		macroCtx.Private["caller"] = caller
 	}
 
 	var b bytes.Buffer
-	err := node.wrapper.Execute(macroCtx, &b)
+	err := node.wrapper.Execute(macroCtx, ctx.limits.writer(&b, true))
+	ctx.limits.releasePending(b.Len())
 	if err != nil {
 		return AsSafeValue(""), err.updateFromTokenIfNeeded(ctx.template, node.position)
 	}
@@ -80,10 +171,73 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 	return AsSafeValue(b.String()), nil
 }
 
+// parseMacroArguments parses the argument names (and their optional default
+// expressions) of a macro or a call block up to the closing parenthesis. The
+// opening parenthesis must already be consumed.
+func parseMacroArguments(arguments *Parser, node *tagMacroNode) *Error {
+	node.args = make(map[string]IEvaluator)
+
+	for arguments.Match(TokenSymbol, ")") == nil {
+		if arguments.Match(TokenSymbol, "*") != nil {
+			if arguments.Match(TokenSymbol, "*") != nil {
+				// **kwargs catches all unknown keyword arguments and must be the last one
+				kwargsToken := arguments.MatchType(TokenIdentifier)
+				if kwargsToken == nil {
+					return arguments.Error("Expected argument name as identifier.", nil)
+				}
+				node.kwargs = kwargsToken.Val
+				if arguments.Match(TokenSymbol, ")") == nil {
+					return arguments.Error(fmt.Sprintf("Expected ')' after '**%s'.", node.kwargs), nil)
+				}
+				break
+			}
+
+			// *varargs catches all extra positional arguments
+			if node.varargs != "" {
+				return arguments.Error(fmt.Sprintf("Only one '*'-argument allowed ('*%s' already defined).", node.varargs), nil)
+			}
+			varargsToken := arguments.MatchType(TokenIdentifier)
+			if varargsToken == nil {
+				return arguments.Error("Expected argument name as identifier.", nil)
+			}
+			node.varargs = varargsToken.Val
+		} else {
+			argNameToken := arguments.MatchType(TokenIdentifier)
+			if argNameToken == nil {
+				return arguments.Error("Expected argument name as identifier.", nil)
+			}
+			if node.varargs != "" {
+				return arguments.Error(fmt.Sprintf("Argument '%s' must be defined before '*%s'.", argNameToken.Val, node.varargs), argNameToken)
+			}
+			node.argsOrder = append(node.argsOrder, argNameToken.Val)
+
+			if arguments.Match(TokenSymbol, "=") != nil {
+				// Default expression follows
+				argDefaultExpr, err := arguments.ParseExpression()
+				if err != nil {
+					return err
+				}
+				node.args[argNameToken.Val] = argDefaultExpr
+			} else {
+				// No default expression
+				node.args[argNameToken.Val] = nil
+			}
+		}
+
+		if arguments.Match(TokenSymbol, ")") != nil {
+			break
+		}
+		if arguments.Match(TokenSymbol, ",") == nil {
+			return arguments.Error("Expected ',' or ')'.", nil)
+		}
+	}
+
+	return nil
+}
+
 func tagMacroParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	macroNode := &tagMacroNode{
 		position: start,
-		args:     make(map[string]IEvaluator),
 	}
 
 	nameToken := arguments.MatchType(TokenIdentifier)
@@ -92,35 +246,18 @@ func tagMacroParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 	}
 	macroNode.name = nameToken.Val
 
+	// A macro must not shadow an imported macro or namespace (see registerImportedName
+	// for the other way round)
+	if filename, has := doc.template.importedNames[macroNode.name]; has {
+		return nil, arguments.Error(fmt.Sprintf("Macro '%s' clashes with the name imported from '%s'.", macroNode.name, filename), nameToken)
+	}
+
 	if arguments.MatchOne(TokenSymbol, "(") == nil {
 		return nil, arguments.Error("Expected '('.", nil)
 	}
 
-	for arguments.Match(TokenSymbol, ")") == nil {
-		argNameToken := arguments.MatchType(TokenIdentifier)
-		if argNameToken == nil {
-			return nil, arguments.Error("Expected argument name as identifier.", nil)
-		}
-		macroNode.argsOrder = append(macroNode.argsOrder, argNameToken.Val)
-
-		if arguments.Match(TokenSymbol, "=") != nil {
-			// Default expression follows
-			argDefaultExpr, err := arguments.ParseExpression()
-			if err != nil {
-				return nil, err
-			}
-			macroNode.args[argNameToken.Val] = argDefaultExpr
-		} else {
-			// No default expression
-			macroNode.args[argNameToken.Val] = nil
-		}
-
-		if arguments.Match(TokenSymbol, ")") != nil {
-			break
-		}
-		if arguments.Match(TokenSymbol, ",") == nil {
-			return nil, arguments.Error("Expected ',' or ')'.", nil)
-		}
+	if err := parseMacroArguments(arguments, macroNode); err != nil {
+		return nil, err
 	}
 
 	if arguments.Match(TokenKeyword, "export") != nil {
@@ -131,8 +268,11 @@ func tagMacroParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, arguments.Error("Malformed macro-tag.", nil)
 	}
 
-	// Body wrapping
+	// Body wrapping; the macro body is not part of any enclosing for-loop
+	loopDepth := doc.loopDepth
+	doc.loopDepth = 0
 	wrapper, endargs, err := doc.WrapUntilTag("endmacro")
+	doc.loopDepth = loopDepth
 	if err != nil {
 		return nil, err
 	}
@@ -142,6 +282,8 @@ func tagMacroParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, endargs.Error("Arguments not allowed here.", nil)
 	}
 
+	doc.template.definedMacros[macroNode.name] = true
+
 	if macroNode.exported {
 		// Now register the macro if it wants to be exported
 		_, has := doc.template.exportedMacros[macroNode.name]
@@ -0,0 +1,118 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// regroupItemName is the name under which the current item is resolved. As the
+// grouper-resolver always starts with it, it can't clash with other variables.
+const regroupItemName = "item"
+
+// regroupGroup is one group created by the regroup-tag. A for-loop can unpack
+// it: {% for grouper, items in groups %}
+type regroupGroup struct {
+	Grouper any
+	List    []any
+}
+
+type tagRegroupNode struct {
+	position *Token
+	list     IEvaluator
+	grouper  *variableResolver // resolves the attribute relative to the item
+	name     string
+}
+
+func (node *tagRegroupNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	list, err := node.list.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	itemCtx := NewChildExecutionContext(ctx)
+
+	// Like Django, only consecutive items with the same grouper are grouped
+	// (the list is expected to be sorted by the grouper)
+	var groups []*regroupGroup
+	var lastGrouper *Value
+	list.Iterate(func(idx, count int, key, value *Value) bool {
+		itemCtx.Private[regroupItemName] = key
+		grouper, gerr := node.grouper.Evaluate(itemCtx)
+		if gerr != nil {
+			err = gerr
+			return false
+		}
+
+		if lastGrouper == nil || !(lastGrouper.EqualValueTo(grouper) || lastGrouper.IsNil() && grouper.IsNil()) {
+			groups = append(groups, &regroupGroup{
+				Grouper: grouper.Interface(),
+			})
+			lastGrouper = grouper
+		}
+		group := groups[len(groups)-1]
+		group.List = append(group.List, key.Interface())
+		return true
+	}, func() {})
+	if err != nil {
+		return err
+	}
+
+	ctx.Private[node.name] = groups
+	return nil
+}
+
+func tagRegroupParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	regroupNode := &tagRegroupNode{
+		position: start,
+	}
+
+	list, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	regroupNode.list = list
+
+	if arguments.Match(TokenIdentifier, "by") == nil {
+		return nil, arguments.Error("Expected 'by' keyword.", nil)
+	}
+
+	// The attribute (path) of the items to group by, e. g. 'country' or 'address.city'
+	attributeToken := arguments.PeekType(TokenIdentifier)
+	if attributeToken == nil {
+		return nil, arguments.Error("Expected an attribute name (identifier) after 'by'.", nil)
+	}
+	attribute, err := arguments.parseVariableOrLiteral()
+	if err != nil {
+		return nil, err
+	}
+	attributeResolver, isResolver := attribute.(*variableResolver)
+	if !isResolver {
+		return nil, arguments.Error(fmt.Sprintf("Invalid attribute '%s' for regroup.", attributeToken.Val), attributeToken)
+	}
+	regroupNode.grouper = &variableResolver{
+		locationToken: attributeToken,
+		parts: append([]*variablePart{{
+			typ: varTypeIdent,
+			s:   regroupItemName,
+		}}, attributeResolver.parts...),
+	}
+
+	if arguments.Match(TokenKeyword, "as") == nil {
+		return nil, arguments.Error("Expected 'as' keyword.", nil)
+	}
+
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Regroup-name must be an identifier.", nil)
+	}
+	regroupNode.name = nameToken.Val
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed regroup-tag arguments.", nil)
+	}
+
+	return regroupNode, nil
+}
+
+func init() {
+	RegisterTag("regroup", tagRegroupParser)
+}
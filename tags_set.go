@@ -1,23 +1,95 @@
 package pongo2
 
+import (
+	"bytes"
+	"fmt"
+)
+
+// namespace is the mutable object created by {% set ns = namespace(key=value, ...) %}.
+// Since it's a reference, attribute updates ({% set ns.key = value %}) are visible
+// outside of the scope they're made in (e. g. after a for-loop).
+type namespace map[string]*Value
+
 type tagSetNode struct {
-	name       string
+	position  *Token
+	name      string
+	attribute string // optional: {% set ns.attribute = ... %}
+
 	expression IEvaluator
+
+	// {% set name = namespace(key=value, ...) %}
+	isNamespace     bool
+	namespaceKeys   []string
+	namespaceValues []IEvaluator
+
+	// {% set name %}...{% endset %}
+	bodyWrapper *NodeWrapper
 }
 
 func (node *tagSetNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	// Evaluate expression
-	value, err := node.expression.Evaluate(ctx)
-	if err != nil {
-		return err
+	var value *Value
+	var bodyErr *Error // a break/continue within the captured body
+
+	switch {
+	case node.bodyWrapper != nil:
+		// Capture the rendered body; it has been escaped already if autoescape
+		// is active (so it must not be escaped again), otherwise it's raw
+		var b bytes.Buffer
+		bodyErr = node.bodyWrapper.Execute(ctx, ctx.limits.writer(&b, true))
+		ctx.limits.releasePending(b.Len())
+		if bodyErr != nil && !isLoopControl(bodyErr) {
+			return bodyErr
+		}
+		if ctx.Autoescape {
+			value = AsSafeValue(b.String())
+		} else {
+			value = AsValue(b.String())
+		}
+	case node.isNamespace:
+		ns := make(namespace, len(node.namespaceKeys))
+		for idx, key := range node.namespaceKeys {
+			v, err := node.namespaceValues[idx].Evaluate(ctx)
+			if err != nil {
+				return err
+			}
+			ns[key] = v
+		}
+		value = AsValue(ns)
+	default:
+		// Evaluate expression
+		var err *Error
+		value, err = node.expression.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
-	ctx.Private[node.name] = value
-	return nil
+	if node.attribute == "" {
+		ctx.Private[node.name] = value
+		return bodyErr
+	}
+
+	// Only attributes of namespaces can be set
+	target, has := ctx.Private[node.name]
+	if !has {
+		target = ctx.Public[node.name]
+	}
+	if v, isValue := target.(*Value); isValue {
+		target = v.Interface()
+	}
+	ns, isNamespace := target.(namespace)
+	if !isNamespace {
+		return ctx.Error(fmt.Sprintf("Can't set attribute '%s' of '%s' since it's not a namespace.",
+			node.attribute, node.name), node.position)
+	}
+	ns[node.attribute] = value
+	return bodyErr
 }
 
 func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	node := &tagSetNode{}
+	node := &tagSetNode{
+		position: start,
+	}
 
 	// Parse variable name
 	typeToken := arguments.MatchType(TokenIdentifier)
@@ -26,16 +98,44 @@ func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 	}
 	node.name = typeToken.Val
 
+	// Namespace attribute
+	if arguments.Match(TokenSymbol, ".") != nil {
+		attributeToken := arguments.MatchType(TokenIdentifier)
+		if attributeToken == nil {
+			return nil, arguments.Error("Expected an attribute name (identifier) after '.'.", nil)
+		}
+		node.attribute = attributeToken.Val
+	}
+
+	if arguments.Remaining() == 0 {
+		// Block form: {% set name %}...{% endset %}
+		wrapper, endargs, err := doc.WrapUntilTag("endset")
+		if err != nil {
+			return nil, err
+		}
+		if endargs.Count() > 0 {
+			return nil, endargs.Error("Arguments not allowed here.", nil)
+		}
+		node.bodyWrapper = wrapper
+		return node, nil
+	}
+
 	if arguments.Match(TokenSymbol, "=") == nil {
 		return nil, arguments.Error("Expected '='.", nil)
 	}
 
-	// Variable expression
-	keyExpression, err := arguments.ParseExpression()
-	if err != nil {
-		return nil, err
+	if arguments.Peek(TokenIdentifier, "namespace") != nil && arguments.PeekN(1, TokenSymbol, "(") != nil {
+		if err := node.parseNamespace(arguments); err != nil {
+			return nil, err
+		}
+	} else {
+		// Variable expression
+		keyExpression, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		node.expression = keyExpression
 	}
-	node.expression = keyExpression
 
 	// Remaining arguments
 	if arguments.Remaining() > 0 {
@@ -45,6 +145,34 @@ func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 	return node, nil
 }
 
+// namespace "(" [ IDENT "=" Expression { "," IDENT "=" Expression } ] ")"
+func (node *tagSetNode) parseNamespace(arguments *Parser) *Error {
+	arguments.Consume() // namespace
+	arguments.Consume() // (
+	node.isNamespace = true
+
+	for arguments.Match(TokenSymbol, ")") == nil {
+		if len(node.namespaceKeys) > 0 && arguments.Match(TokenSymbol, ",") == nil {
+			return arguments.Error("Expected ',' or ')' in namespace().", nil)
+		}
+		keyToken := arguments.MatchType(TokenIdentifier)
+		if keyToken == nil {
+			return arguments.Error("Expected an attribute name (identifier) in namespace().", nil)
+		}
+		if arguments.Match(TokenSymbol, "=") == nil {
+			return arguments.Error("Expected '='.", nil)
+		}
+		valueExpr, err := arguments.ParseExpression()
+		if err != nil {
+			return err
+		}
+		node.namespaceKeys = append(node.namespaceKeys, keyToken.Val)
+		node.namespaceValues = append(node.namespaceValues, valueExpr)
+	}
+
+	return nil
+}
+
 func init() {
 	RegisterTag("set", tagSetParser)
 }
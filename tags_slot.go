@@ -0,0 +1,72 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// defaultSlotName is the name of a slot without a name ({% slot %}). When calling a
+// component, everything outside of named slots fills the default slot.
+const defaultSlotName = "default"
+
+// componentSlot is a slot filled by the caller of a component; it's rendered
+// within the caller's context.
+type componentSlot struct {
+	ctx     *ExecutionContext
+	wrapper *NodeWrapper
+}
+
+type tagSlotNode struct {
+	position *Token
+	name     string
+	wrapper  *NodeWrapper
+}
+
+// Execute renders the slot's placeholder within a component template: the
+// content given by the component's caller or the default content otherwise.
+func (node *tagSlotNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if slot, has := ctx.slots[node.name]; has {
+		return slot.wrapper.Execute(slot.ctx, writer)
+	}
+	return node.wrapper.Execute(ctx, writer)
+}
+
+func tagSlotParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	slotNode := &tagSlotNode{
+		position: start,
+		name:     defaultSlotName,
+	}
+
+	if arguments.Remaining() > 0 {
+		nameToken := arguments.MatchType(TokenIdentifier)
+		if nameToken == nil {
+			return nil, arguments.Error("Slot name must be an identifier.", nil)
+		}
+		slotNode.name = nameToken.Val
+
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Tag 'slot' takes at most 1 argument (an identifier).", nil)
+		}
+	}
+
+	wrapper, endtagargs, err := doc.WrapUntilTag("endslot")
+	if err != nil {
+		return nil, err
+	}
+	if endtagargs.Remaining() > 0 {
+		endtagnameToken := endtagargs.MatchType(TokenIdentifier)
+		if endtagnameToken != nil && endtagnameToken.Val != slotNode.name {
+			return nil, endtagargs.Error(fmt.Sprintf("Name for 'endslot' must equal to 'slot'-tag's name ('%s' != '%s').",
+				slotNode.name, endtagnameToken.Val), nil)
+		}
+		if endtagnameToken == nil || endtagargs.Remaining() > 0 {
+			return nil, endtagargs.Error("Either no or only one argument (identifier) allowed for 'endslot'.", nil)
+		}
+	}
+	slotNode.wrapper = wrapper
+
+	return slotNode, nil
+}
+
+func init() {
+	RegisterTag("slot", tagSlotParser)
+}
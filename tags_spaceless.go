@@ -14,8 +14,10 @@ var tagSpacelessRegexp = regexp.MustCompile(`(?U:(<.*>))([\t\n\v\f\r ]+)(?U:(<.*
 func (node *tagSpacelessNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	b := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
 
-	err := node.wrapper.Execute(ctx, b)
-	if err != nil {
+	// The output rendered before a break/continue is written as well
+	err := node.wrapper.Execute(ctx, ctx.limits.writer(b, true))
+	ctx.limits.releasePending(b.Len())
+	if err != nil && !isLoopControl(err) {
 		return err
 	}
 
@@ -30,7 +32,7 @@ func (node *tagSpacelessNode) Execute(ctx *ExecutionContext, writer TemplateWrit
 
 	writer.WriteString(s)
 
-	return nil
+	return err
 }
 
 func tagSpacelessParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
@@ -15,9 +15,9 @@ func (node *tagSSINode) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 		includeCtx.Update(ctx.Public)
 		includeCtx.Update(ctx.Private)
 
-		err := node.template.execute(includeCtx, writer)
+		err := node.template.executeIncluded(ctx, includeCtx, writer)
 		if err != nil {
-			return err.(*Error)
+			return err
 		}
 	} else {
 		// Just print out the content
@@ -39,6 +39,7 @@ func tagSSIParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 				return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, fileToken)
 			}
 			SSINode.template = temporaryTpl
+			doc.template.addDependency(temporaryTpl)
 		} else {
 			// plaintext
 			buf, err := os.ReadFile(doc.template.set.resolveFilename(doc.template, fileToken.Val))
@@ -0,0 +1,137 @@
+package pongo2
+
+import (
+	"strings"
+)
+
+type tagSwitchCase struct {
+	values  []IEvaluator
+	wrapper *NodeWrapper
+}
+
+type tagSwitchNode struct {
+	subject        IEvaluator
+	cases          []*tagSwitchCase
+	defaultWrapper *NodeWrapper
+}
+
+func (node *tagSwitchNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The subject is evaluated only once
+	subject, err := node.subject.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range node.cases {
+		for _, valueExpr := range c.values {
+			value, err := valueExpr.Evaluate(ctx)
+			if err != nil {
+				return err
+			}
+			if subject.EqualValueTo(value) {
+				return c.wrapper.Execute(ctx, writer)
+			}
+		}
+	}
+
+	if node.defaultWrapper != nil {
+		return node.defaultWrapper.Execute(ctx, writer)
+	}
+
+	return nil
+}
+
+// parseCaseValue parses one of the values of a case-tag. As the values are separated
+// by whitespace (or commas), a value is a single operand only: a literal (numbers
+// with an optional sign), a variable (both with optional filters) or an expression
+// in parentheses. Thus {% case 1 -1 %} has the values 1 and -1, while a computed
+// value must be written as {% case (limit - 1) %}.
+func parseCaseValue(arguments *Parser) (IEvaluator, *Error) {
+	sign := arguments.MatchOne(TokenSymbol, "+", "-")
+	value, err := arguments.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	if sign == nil {
+		return value, nil
+	}
+	return &simpleExpression{
+		negativeSign: sign.Val == "-",
+		term1:        value,
+	}, nil
+}
+
+func tagSwitchParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	switchNode := &tagSwitchNode{}
+
+	subject, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	switchNode.subject = subject
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Switch-subject is malformed.", nil)
+	}
+
+	// Only whitespace is allowed before the first case
+	wrapper, tagArgs, err := doc.WrapUntilTag("case", "default", "endswitch")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range wrapper.nodes {
+		html, isHTML := n.(*nodeHTML)
+		if !isHTML || strings.TrimSpace(html.token.Val) != "" {
+			return nil, doc.Error("Only whitespace is allowed between 'switch' and the first 'case'.", start)
+		}
+	}
+
+	for wrapper.Endtag != "endswitch" {
+		endtag := wrapper.Endtag
+		if switchNode.defaultWrapper != nil {
+			return nil, tagArgs.Error("No 'case' or 'default' allowed after 'default'.", nil)
+		}
+
+		var values []IEvaluator
+		if endtag == "case" {
+			// case takes at least one value: {% case "a" "b" %}
+			for tagArgs.Remaining() > 0 {
+				value, err := parseCaseValue(tagArgs)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+				tagArgs.Match(TokenSymbol, ",") // optional separator
+			}
+			if len(values) == 0 {
+				return nil, tagArgs.Error("Tag 'case' requires at least one value.", nil)
+			}
+		} else if tagArgs.Count() > 0 {
+			return nil, tagArgs.Error("Arguments not allowed here.", nil)
+		}
+
+		wrapper, tagArgs, err = doc.WrapUntilTag("case", "default", "endswitch")
+		if err != nil {
+			return nil, err
+		}
+
+		if endtag == "case" {
+			switchNode.cases = append(switchNode.cases, &tagSwitchCase{
+				values:  values,
+				wrapper: wrapper,
+			})
+		} else {
+			switchNode.defaultWrapper = wrapper
+		}
+	}
+
+	if tagArgs.Count() > 0 {
+		return nil, tagArgs.Error("Arguments not allowed here.", nil)
+	}
+
+	return switchNode, nil
+}
+
+func init() {
+	RegisterTag("switch", tagSwitchParser)
+}
@@ -2,8 +2,10 @@ package pongo2
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -37,12 +39,19 @@ type Template struct {
 	tokens []*Token
 	parser *Parser
 
+	// Revalidation (see TemplateSet.RevalidateCache)
+	source       *templateSource // nil if the template's loader doesn't report versions
+	dependencies []*Template     // templates compiled as part of this template (e. g. parents, includes and imports)
+
 	// first come, first serve (it's important to not override existing entries in here)
 	level          int
-	parent         *Template
-	child          *Template
+	parent         *Template       // static parent ({% extends "file" %})
+	extends        *tagExtendsNode // nil if the template doesn't extend another one
 	blocks         map[string]*NodeWrapper
+	requiredBlocks map[string]*Token // name -> name token of the blocks tagged as required
 	exportedMacros map[string]*tagMacroNode
+	definedMacros  map[string]bool   // names of all macros defined by the template (exported or not)
+	importedNames  map[string]string // name -> filename of the imported macros and namespaces
 
 	// Output
 	root *nodeDocument
@@ -53,7 +62,14 @@ type Template struct {
 }
 
 func newTemplateString(set *TemplateSet, tpl []byte) (*Template, error) {
-	return newTemplate(set, "<string>", true, tpl)
+	t, err := newTemplate(set, "<string>", true, tpl)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkRequiredBlocks(); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*Template, error) {
@@ -67,7 +83,10 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 		tpl:            strTpl,
 		size:           len(strTpl),
 		blocks:         make(map[string]*NodeWrapper),
+		requiredBlocks: make(map[string]*Token),
 		exportedMacros: make(map[string]*tagMacroNode),
+		definedMacros:  make(map[string]bool),
+		importedNames:  make(map[string]string),
 		Options:        newOptions(),
 	}
 	// Copy all settings from another Options.
@@ -94,7 +113,9 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 	return t, nil
 }
 
-func (tpl *Template) newContextForExecution(context Context) (*Template, *ExecutionContext, error) {
+// newContextForExecution creates the context to execute tpl with. overrides (if
+// not nil) holds blocks which take precedence over the ones of tpl (see the embed-tag).
+func (tpl *Template) newContextForExecution(goctx context.Context, data Context, overrides *Template) (*Template, *ExecutionContext, error) {
 	if tpl.Options.TrimBlocks || tpl.Options.LStripBlocks {
 		// Issue #94 https://github.com/flosch/pongo2/issues/94
 		// If an application configures pongo2 template to trim_blocks,
@@ -123,31 +144,25 @@ func (tpl *Template) newContextForExecution(context Context) (*Template, *Execut
 		}
 	}
 
-	// Determine the parent to be executed (for template inheritance)
-	parent := tpl
-	for parent.parent != nil {
-		parent = parent.parent
-	}
-
 	// Create context if none is given
 	newContext := make(Context)
 	newContext.Update(tpl.set.Globals)
 
-	if context != nil {
-		newContext.Update(context)
+	if data != nil {
+		newContext.Update(data)
 
 		if len(newContext) > 0 {
 			// Check for context name syntax
 			err := newContext.checkForValidIdentifiers()
 			if err != nil {
-				return parent, nil, err
+				return tpl, nil, err
 			}
 
 			// Check for clashes with macro names
 			for k := range newContext {
 				_, has := tpl.exportedMacros[k]
 				if has {
-					return parent, nil, &Error{
+					return tpl, nil, &Error{
 						Filename:  tpl.name,
 						Sender:    "execution",
 						OrigError: fmt.Errorf("context key name '%s' clashes with macro '%s'", k, k),
@@ -158,34 +173,146 @@ func (tpl *Template) newContextForExecution(context Context) (*Template, *Execut
 	}
 
 	// Create operational context
-	ctx := newExecutionContext(parent, newContext)
+	ctx := newExecutionContext(goctx, tpl, newContext)
+	ctx.strictUndefined = tpl.Options.StrictUndefined
+
+	// Determine the parent to be executed (for template inheritance); dynamic
+	// parents are evaluated within the template's context.
+	parent := tpl
+	ctx.inheritance = []*Template{tpl}
+	for {
+		next, err := parent.resolveParent(ctx)
+		if err != nil {
+			return parent, nil, err
+		}
+		if next == nil {
+			break
+		}
+		for _, t := range ctx.inheritance {
+			if t.name == next.name {
+				return parent, nil, ctx.Error(fmt.Sprintf("Template '%s' is extended recursively.", next.name), parent.extends.position)
+			}
+		}
+		ctx.inheritance = append(ctx.inheritance, next)
+		parent = next
+	}
+	if overrides != nil {
+		ctx.inheritance = append([]*Template{overrides}, ctx.inheritance...)
+	}
+	ctx.template = parent
 
 	return parent, ctx, nil
 }
 
-func (tpl *Template) execute(context Context, writer TemplateWriter) error {
-	parent, ctx, err := tpl.newContextForExecution(context)
+// execute executes the template and writes the output to writer. If fragment is
+// not nil, only the output of the fragment's block is written (see ExecuteBlock).
+func (tpl *Template) execute(goctx context.Context, data Context, writer TemplateWriter, fragment *blockFragment) error {
+	if goctx == nil {
+		goctx = context.Background()
+	}
+	parent, ctx, err := tpl.newContextForExecution(goctx, data, nil)
 	if err != nil {
 		return err
 	}
+	// Required blocks of dynamic parents can only be checked now
+	if err := checkRequiredBlocks(ctx.inheritance, "execution"); err != nil {
+		return err
+	}
+
+	// Enforce the set's resource limits (if any)
+	var cancel context.CancelFunc
+	ctx.limits, ctx.goctx, cancel = newExecutionLimits(tpl.set.Limits, goctx)
+	defer cancel()
+	writer = ctx.limits.writer(writer, false)
+
+	if fragment != nil {
+		if !fragment.defined(ctx) {
+			return ctx.Error(fmt.Sprintf("Block '%s' not found.", fragment.name), nil)
+		}
+
+		// Everything but the block is discarded
+		fragment.writer = writer
+		writer = &templateWriter{w: io.Discard}
+		ctx.fragment = fragment
+	}
 
 	// Run the selected document
 	if err := parent.root.Execute(ctx, writer); err != nil {
+		if fragment != nil && fragment.done {
+			// The execution has been stopped after the block was rendered
+			return nil
+		}
+		return err
+	}
+
+	// The output limit might have been exceeded by the very last node
+	if err := ctx.checkOutputLimit(nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (tpl *Template) newTemplateWriterAndExecute(context Context, writer io.Writer) error {
-	return tpl.execute(context, &templateWriter{w: writer})
+// executeIncluded executes the template as part of another template's execution
+// (e. g. for the include-tag). The resource limits of the including execution are
+// shared. Nothing is written on error.
+func (tpl *Template) executeIncluded(parentCtx *ExecutionContext, data Context, writer TemplateWriter) *Error {
+	parent, ctx, err := tpl.newIncludedContext(parentCtx, data, nil)
+	if err != nil {
+		return err
+	}
+	return parent.executeIncludedRoot(ctx, writer)
+}
+
+// newIncludedContext creates the context to execute the template as part of
+// another template's execution (see executeIncluded and newContextForExecution).
+func (tpl *Template) newIncludedContext(parentCtx *ExecutionContext, data Context, overrides *Template) (*Template, *ExecutionContext, *Error) {
+	parent, ctx, err := tpl.newContextForExecution(parentCtx.goctx, data, overrides)
+	if err != nil {
+		return nil, nil, err.(*Error)
+	}
+	// Required blocks of dynamic parents (or of embedded templates) can only be
+	// checked now
+	if err := checkRequiredBlocks(ctx.inheritance, "execution"); err != nil {
+		return nil, nil, err
+	}
+	ctx.includeDepth = parentCtx.includeDepth + 1
+	ctx.limits = parentCtx.limits
+	return parent, ctx, nil
 }
 
-func (tpl *Template) newBufferAndExecute(context Context) (*bytes.Buffer, error) {
+// executeIncludedRoot executes the (base) template with a context created by
+// newIncludedContext. Nothing is written on error. The buffered output counts
+// towards the output limit of the execution.
+func (tpl *Template) executeIncludedRoot(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
+	err := tpl.root.Execute(ctx, ctx.limits.writer(buffer, true))
+	ctx.limits.releasePending(buffer.Len())
+	if err != nil {
+		return err
+	}
+	if err := ctx.checkOutputLimit(nil); err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(buffer.Bytes()); err != nil {
+		if limitErr := ctx.checkOutputLimit(nil); limitErr != nil {
+			return limitErr
+		}
+		return ctx.OrigError(err, nil)
+	}
+	return nil
+}
+
+func (tpl *Template) newTemplateWriterAndExecute(goctx context.Context, data Context, writer io.Writer) error {
+	return tpl.execute(goctx, data, &templateWriter{w: writer}, nil)
+}
+
+func (tpl *Template) newBufferAndExecute(goctx context.Context, data Context) (*bytes.Buffer, error) {
 	// Create output buffer
 	// We assume that the rendered template will be 30% larger
 	buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
-	if err := tpl.execute(context, buffer); err != nil {
+	if err := tpl.execute(goctx, data, buffer, nil); err != nil {
 		return nil, err
 	}
 	return buffer, nil
@@ -194,8 +321,16 @@ func (tpl *Template) newBufferAndExecute(context Context) (*bytes.Buffer, error)
 // Executes the template with the given context and writes to writer (io.Writer)
 // on success. Context can be nil. Nothing is written on error; instead the error
 // is being returned.
-func (tpl *Template) ExecuteWriter(context Context, writer io.Writer) error {
-	buf, err := tpl.newBufferAndExecute(context)
+func (tpl *Template) ExecuteWriter(data Context, writer io.Writer) error {
+	return tpl.ExecuteContext(context.Background(), data, writer)
+}
+
+// ExecuteContext behaves like ExecuteWriter, but aborts the execution as soon as
+// goctx is canceled or its deadline is exceeded. In this case the returned error
+// wraps goctx.Err(). The context.Context is available to tags, filters and functions
+// through ExecutionContext.Context(). A nil goctx is treated as context.Background().
+func (tpl *Template) ExecuteContext(goctx context.Context, data Context, writer io.Writer) error {
+	buf, err := tpl.newBufferAndExecute(goctx, data)
 	if err != nil {
 		return err
 	}
@@ -211,14 +346,20 @@ func (tpl *Template) ExecuteWriter(context Context, writer io.Writer) error {
 // case of an execution error because there's no intermediate buffer involved for
 // performance reasons. This is handy if you need high performance template
 // generation or if you want to manage your own pool of buffers.
-func (tpl *Template) ExecuteWriterUnbuffered(context Context, writer io.Writer) error {
-	return tpl.newTemplateWriterAndExecute(context, writer)
+func (tpl *Template) ExecuteWriterUnbuffered(data Context, writer io.Writer) error {
+	return tpl.ExecuteWriterUnbufferedContext(context.Background(), data, writer)
+}
+
+// ExecuteWriterUnbufferedContext behaves like ExecuteWriterUnbuffered, but honors
+// the cancellation and deadline of goctx (see ExecuteContext).
+func (tpl *Template) ExecuteWriterUnbufferedContext(goctx context.Context, data Context, writer io.Writer) error {
+	return tpl.newTemplateWriterAndExecute(goctx, data, writer)
 }
 
 // Executes the template and returns the rendered template as a []byte
-func (tpl *Template) ExecuteBytes(context Context) ([]byte, error) {
+func (tpl *Template) ExecuteBytes(data Context) ([]byte, error) {
 	// Execute template
-	buffer, err := tpl.newBufferAndExecute(context)
+	buffer, err := tpl.newBufferAndExecute(context.Background(), data)
 	if err != nil {
 		return nil, err
 	}
@@ -226,9 +367,9 @@ func (tpl *Template) ExecuteBytes(context Context) ([]byte, error) {
 }
 
 // Executes the template and returns the rendered template as a string
-func (tpl *Template) Execute(context Context) (string, error) {
+func (tpl *Template) Execute(data Context) (string, error) {
 	// Execute template
-	buffer, err := tpl.newBufferAndExecute(context)
+	buffer, err := tpl.newBufferAndExecute(context.Background(), data)
 	if err != nil {
 		return "", err
 	}
@@ -236,15 +377,61 @@ func (tpl *Template) Execute(context Context) (string, error) {
 	return buffer.String(), nil
 }
 
-func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string]string, error) {
+// ExecuteBlock executes the template, but only writes the output of the block
+// name to writer. The output equals the one of the block within a full execution
+// of the template: overriding blocks of child templates, block.Super, nested
+// blocks and all variables set before the block are taken into account. The
+// execution stops once the block has been rendered. As there is no intermediate
+// buffer (see ExecuteWriterUnbuffered), parts of the block might have been
+// written in case of an execution error.
+//
+// An error is returned if neither the template nor any of its parents defines
+// the block. Nothing is written if the block isn't reached during the execution
+// (e. g. because it's within an if-tag whose condition is false).
+func (tpl *Template) ExecuteBlock(name string, data Context, writer io.Writer) error {
+	return tpl.ExecuteBlockContext(context.Background(), name, data, writer)
+}
+
+// ExecuteBlockContext behaves like ExecuteBlock, but honors the cancellation
+// and deadline of goctx (see ExecuteContext).
+func (tpl *Template) ExecuteBlockContext(goctx context.Context, name string, data Context, writer io.Writer) error {
+	return tpl.execute(goctx, data, &templateWriter{w: writer}, &blockFragment{name: name})
+}
+
+// ExecuteBlocks executes the given blocks of the template (or of its parents)
+// individually and returns their output by name; blocks which aren't defined
+// are missing in the result. Unlike ExecuteBlock, the blocks are executed on
+// their own, outside of the template's inheritance.
+func (tpl *Template) ExecuteBlocks(data Context, blocks []string) (map[string]string, error) {
+	return tpl.ExecuteBlocksContext(context.Background(), data, blocks)
+}
+
+// ExecuteBlocksContext behaves like ExecuteBlocks, but honors the cancellation
+// and deadline of goctx (see ExecuteContext).
+func (tpl *Template) ExecuteBlocksContext(goctx context.Context, data Context, blocks []string) (map[string]string, error) {
+	if goctx == nil {
+		goctx = context.Background()
+	}
 	var parents []*Template
 	result := make(map[string]string)
 
+	// The required blocks are checked against the inheritance of tpl (the contexts
+	// below are created for its parents alone)
+	if chain, ok := tpl.staticInheritance(); ok {
+		if err := checkRequiredBlocks(chain, "execution"); err != nil {
+			return nil, err
+		}
+	}
+
+	// The set's resource limits apply to all blocks together
+	limits, goctx, cancel := newExecutionLimits(tpl.set.Limits, goctx)
+	defer cancel()
+
 	parent := tpl
 	for parent != nil {
 		// We only want to execute the template if it has a block we want
 		for _, block := range blocks {
-			if _, ok := tpl.blocks[block]; ok {
+			if _, ok := parent.blocks[block]; ok {
 				parents = append(parents, parent)
 				break
 			}
@@ -267,15 +454,19 @@ func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string
 				}
 				// assign the context if we haven't done so
 				if ctx == nil {
-					_, ctx, err = t.newContextForExecution(context)
+					_, ctx, err = t.newContextForExecution(goctx, data, nil)
 					if err != nil {
 						return nil, err
 					}
+					ctx.limits = limits
 				}
-				bErr := blockWrapper.Execute(ctx, buffer)
+				bErr := blockWrapper.Execute(ctx, limits.writer(buffer, false))
 				if bErr != nil {
 					return nil, bErr
 				}
+				if bErr := ctx.checkOutputLimit(nil); bErr != nil {
+					return nil, bErr
+				}
 				result[blockName] = buffer.String()
 				buffer.Reset()
 			}
@@ -288,3 +479,37 @@ func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string
 
 	return result, nil
 }
+
+// addDependency records a template compiled as part of tpl (e. g. its static parent
+// or an included template) which must be revalidated along with tpl.
+func (tpl *Template) addDependency(dependency *Template) {
+	tpl.dependencies = append(tpl.dependencies, dependency)
+}
+
+// Blocks returns the sorted names of the blocks defined by the template itself
+// (without the ones of its parents).
+func (tpl *Template) Blocks() []string {
+	return sortedNames(tpl.blocks)
+}
+
+// Parent returns the template extended by tpl or nil if tpl doesn't extend another
+// template. It's nil as well if the parent is determined during execution
+// (e. g. {% extends layout %}).
+func (tpl *Template) Parent() *Template {
+	return tpl.parent
+}
+
+// Macros returns the sorted names of the macros exported by the template (which
+// can be imported by other templates).
+func (tpl *Template) Macros() []string {
+	return sortedNames(tpl.exportedMacros)
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,6 +2,9 @@ package pongo2
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -31,6 +34,42 @@ func (l *FSLoader) Get(path string) (io.Reader, error) {
 	return l.fs.Open(path)
 }
 
+// Version returns the modification time and size of the template (or a hash of
+// its content if the file system doesn't provide modification times). Templates
+// of an embed.FS can't change, so they always report the same version.
+func (l *FSLoader) Version(path string) (string, error) {
+	if _, ok := l.fs.(embed.FS); ok {
+		return "", nil
+	}
+	fi, err := fs.Stat(l.fs, path)
+	if err != nil {
+		return "", err
+	}
+	if fi.ModTime().IsZero() {
+		f, err := l.fs.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return contentVersion(f)
+	}
+	return fileInfoVersion(fi), nil
+}
+
+// fileInfoVersion returns a template version based on the file's modification time and size.
+func fileInfoVersion(fi fs.FileInfo) string {
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size())
+}
+
+// contentVersion returns a template version based on a hash of the content.
+func contentVersion(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // LocalFilesystemLoader represents a local filesystem loader with basic
 // BaseDirectory capabilities. The access to the local filesystem is unrestricted.
 type LocalFilesystemLoader struct {
@@ -98,6 +137,15 @@ func (fs *LocalFilesystemLoader) Get(path string) (io.Reader, error) {
 	return bytes.NewReader(buf), nil
 }
 
+// Version returns the modification time and size of the template file.
+func (fs *LocalFilesystemLoader) Version(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fileInfoVersion(fi), nil
+}
+
 // Abs resolves a filename relative to the base directory. Absolute paths are allowed.
 // When there's no base dir set, the absolute path to the filename
 // will be calculated based on either the provided base directory (which
@@ -230,3 +278,23 @@ func (h *HttpFilesystemLoader) Get(path string) (io.Reader, error) {
 
 	return h.fs.Open(fullPath)
 }
+
+// Version returns the modification time and size of the template (or a hash of
+// its content if the file system doesn't provide modification times).
+func (h *HttpFilesystemLoader) Version(path string) (string, error) {
+	r, err := h.Get(path)
+	if err != nil {
+		return "", err
+	}
+	f := r.(http.File)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if fi.ModTime().IsZero() {
+		return contentVersion(f)
+	}
+	return fileInfoVersion(fi), nil
+}
@@ -6,7 +6,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 // TemplateLoader allows to implement a virtual file system.
@@ -19,6 +21,20 @@ type TemplateLoader interface {
 	Get(path string) (io.Reader, error)
 }
 
+// TemplateVersioner can be implemented by a TemplateLoader to report the version
+// of a template. It's used to detect changed templates if the template set's
+// RevalidateCache is enabled.
+type TemplateVersioner interface {
+	// Version returns an opaque version of the template at path (e. g. based on its
+	// modification time and size or a hash of its content) which changes whenever
+	// the template's content changes.
+	Version(path string) (string, error)
+}
+
+// DefaultRevalidateInterval is the minimum time between two checks of the same
+// cached template if TemplateSet.RevalidateInterval isn't set.
+const DefaultRevalidateInterval = time.Second
+
 // TemplateSet allows you to create your own group of templates with their own
 // global context (which is shared among all members of the set) and their own
 // configuration.
@@ -37,6 +53,21 @@ type TemplateSet struct {
 	// variable during program execution (and template compilation/execution).
 	Debug bool
 
+	// If RevalidateCache is true (default false), FromCache() checks whether a cached
+	// template or any template it depends on (like its parents, included or imported
+	// templates) has changed since it has been compiled and recompiles it if so.
+	// Unchanged templates stay cached. Changes are only detected for templates whose
+	// loader implements TemplateVersioner (like the loaders of pongo2 do); any other
+	// template stays cached until CleanCache() is called. The template chosen from a
+	// fallback list (e. g. {% include ["custom/row.html", "row.html"] %}) is
+	// revalidated as well, so a template added in front of it will be used.
+	RevalidateCache bool
+
+	// RevalidateInterval is the minimum time between two checks of the same cached
+	// template (or fallback list) if RevalidateCache is enabled. Zero means
+	// DefaultRevalidateInterval.
+	RevalidateInterval time.Duration
+
 	// Options allow you to change the behavior of template-engine.
 	// You can change the options before calling the Execute method.
 	Options *Options
@@ -51,9 +82,28 @@ type TemplateSet struct {
 	bannedTags           map[string]bool
 	bannedFilters        map[string]bool
 
+	// Limits restrict the resources (output size, loop iterations, include depth and
+	// execution time) a single execution of a template of this set may consume.
+	// By default no limits are applied. See Limits for details.
+	Limits Limits
+
+	// Set-local filters, tags and tests (see RegisterFilter(), RegisterTag() and
+	// RegisterTest()). They take precedence over the globally registered ones.
+	filters map[string]*filter
+	tags    map[string]*tag
+	tests   map[string]TestFunction
+
 	// Template cache (for FromCache())
 	templateCache      map[string]*Template
 	templateCacheMutex sync.Mutex
+
+	// The chosen template of a fallback list (for fromCacheFirstFound())
+	firstFoundCache map[string]string
+
+	// When the cached templates and fallback list choices have been (re)validated
+	// the last time (see RevalidateCache)
+	templateChecked   map[string]time.Time
+	firstFoundChecked map[string]time.Time
 }
 
 // NewSet can be used to create sets with different kind of templates
@@ -65,13 +115,20 @@ func NewSet(name string, loaders ...TemplateLoader) *TemplateSet {
 	}
 
 	return &TemplateSet{
-		name:          name,
-		loaders:       loaders,
-		Globals:       make(Context),
-		bannedTags:    make(map[string]bool),
-		bannedFilters: make(map[string]bool),
-		templateCache: make(map[string]*Template),
-		Options:       newOptions(),
+		name:            name,
+		loaders:         loaders,
+		Globals:         make(Context),
+		bannedTags:      make(map[string]bool),
+		bannedFilters:   make(map[string]bool),
+		filters:         make(map[string]*filter),
+		tags:            make(map[string]*tag),
+		tests:           make(map[string]TestFunction),
+		templateCache:   make(map[string]*Template),
+		firstFoundCache: make(map[string]string),
+		Options:         newOptions(),
+
+		templateChecked:   make(map[string]time.Time),
+		firstFoundChecked: make(map[string]time.Time),
 	}
 }
 
@@ -95,16 +152,120 @@ func (set *TemplateSet) resolveFilenameForLoader(loader TemplateLoader, tpl *Tem
 	return loader.Abs(name, path)
 }
 
+// RegisterFilter registers a new filter which is only available to templates
+// of this set. A set-local filter may shadow a globally registered filter with
+// the same name (e. g. to override a built-in filter); other sets are not affected.
+func (set *TemplateSet) RegisterFilter(name string, fn FilterFunction) error {
+	return set.RegisterContextFilter(name, fn.withContext())
+}
+
+// RegisterContextFilter registers a new filter for this set which gets access to
+// the current ExecutionContext (see RegisterContextFilter and TemplateSet.RegisterFilter).
+func (set *TemplateSet) RegisterContextFilter(name string, fn ContextFilterFunction) error {
+	if _, existing := set.filters[name]; existing {
+		return fmt.Errorf("filter with name '%s' is already registered in template set '%s'", name, set.name)
+	}
+	set.filters[name] = &filter{fn: fn}
+	return nil
+}
+
+// RegisterArgsFilter registers a new filter for this set which accepts multiple
+// positional and keyword arguments (see RegisterArgsFilter and TemplateSet.RegisterFilter).
+func (set *TemplateSet) RegisterArgsFilter(name string, params []FilterParameter, fn ArgsFilterFunction) error {
+	if _, existing := set.filters[name]; existing {
+		return fmt.Errorf("filter with name '%s' is already registered in template set '%s'", name, set.name)
+	}
+	if err := checkFilterParameters(params); err != nil {
+		return err
+	}
+	set.filters[name] = &filter{argsFn: fn, params: params}
+	return nil
+}
+
+// RegisterTag registers a new tag which is only available to templates
+// of this set. A set-local tag may shadow a globally registered tag with
+// the same name; other sets are not affected.
+func (set *TemplateSet) RegisterTag(name string, parserFn TagParser) error {
+	if _, existing := set.tags[name]; existing {
+		return fmt.Errorf("tag with name '%s' is already registered in template set '%s'", name, set.name)
+	}
+	set.tags[name] = &tag{
+		name:   name,
+		parser: parserFn,
+	}
+	return nil
+}
+
+// RegisterTest registers a new test (used with the is-operator) which is only
+// available to templates of this set. A set-local test may shadow a globally
+// registered test with the same name; other sets are not affected.
+func (set *TemplateSet) RegisterTest(name string, fn TestFunction) error {
+	if _, existing := set.tests[name]; existing {
+		return fmt.Errorf("test with name '%s' is already registered in template set '%s'", name, set.name)
+	}
+	set.tests[name] = fn
+	return nil
+}
+
+// FilterExists returns true if the given filter is available to templates of
+// this set (either registered for this set or globally).
+func (set *TemplateSet) FilterExists(name string) bool {
+	_, existing := set.filter(name)
+	return existing
+}
+
+// TagExists returns true if the given tag is available to templates of
+// this set (either registered for this set or globally).
+func (set *TemplateSet) TagExists(name string) bool {
+	_, existing := set.tag(name)
+	return existing
+}
+
+// filter looks up a filter; set-local filters have priority over the
+// global ones.
+func (set *TemplateSet) filter(name string) (*filter, bool) {
+	if f, existing := set.filters[name]; existing {
+		return f, true
+	}
+	f, existing := filters[name]
+	return f, existing
+}
+
+// TestExists returns true if the given test is available to templates of
+// this set (either registered for this set or globally).
+func (set *TemplateSet) TestExists(name string) bool {
+	_, existing := set.test(name)
+	return existing
+}
+
+// test looks up a test function; set-local tests have priority over the
+// global ones.
+func (set *TemplateSet) test(name string) (TestFunction, bool) {
+	if fn, existing := set.tests[name]; existing {
+		return fn, true
+	}
+	fn, existing := tests[name]
+	return fn, existing
+}
+
+// tag looks up a tag; set-local tags have priority over the global ones.
+func (set *TemplateSet) tag(name string) (*tag, bool) {
+	if t, existing := set.tags[name]; existing {
+		return t, true
+	}
+	t, existing := tags[name]
+	return t, existing
+}
+
 // BanTag bans a specific tag for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanTag(name string) error {
-	_, has := tags[name]
-	if !has {
+	if !set.TagExists(name) {
 		return fmt.Errorf("tag '%s' not found", name)
 	}
 	if set.firstTemplateCreated {
 		return errors.New("you cannot ban any tags after you've added your first template to your template set")
 	}
-	_, has = set.bannedTags[name]
+	_, has := set.bannedTags[name]
 	if has {
 		return fmt.Errorf("tag '%s' is already banned", name)
 	}
@@ -115,14 +276,13 @@ func (set *TemplateSet) BanTag(name string) error {
 
 // BanFilter bans a specific filter for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanFilter(name string) error {
-	_, has := filters[name]
-	if !has {
+	if !set.FilterExists(name) {
 		return fmt.Errorf("filter '%s' not found", name)
 	}
 	if set.firstTemplateCreated {
 		return errors.New("you cannot ban any filters after you've added your first template to your template set")
 	}
-	_, has = set.bannedFilters[name]
+	_, has := set.bannedFilters[name]
 	if has {
 		return fmt.Errorf("filter '%s' is already banned", name)
 	}
@@ -141,7 +301,7 @@ func (set *TemplateSet) resolveTemplate(tpl *Template, path string) (name string
 		}
 	}
 
-	return path, nil, nil, fmt.Errorf("unable to resolve template")
+	return path, nil, nil, &TemplateNotFoundError{Names: []string{path}}
 }
 
 // CleanCache cleans the template cache. If filenames is not empty,
@@ -153,10 +313,17 @@ func (set *TemplateSet) CleanCache(filenames ...string) {
 
 	if len(filenames) == 0 {
 		set.templateCache = make(map[string]*Template, len(set.templateCache))
+		set.templateChecked = make(map[string]time.Time, len(set.templateChecked))
 	}
 
+	// Fallback lists might resolve differently now
+	set.firstFoundCache = make(map[string]string)
+	set.firstFoundChecked = make(map[string]time.Time)
+
 	for _, filename := range filenames {
-		delete(set.templateCache, set.resolveFilename(nil, filename))
+		cleanedFilename := set.resolveFilename(nil, filename)
+		delete(set.templateCache, cleanedFilename)
+		delete(set.templateChecked, cleanedFilename)
 	}
 }
 
@@ -166,30 +333,151 @@ func (set *TemplateSet) CleanCache(filenames ...string) {
 // FromCache() will not cache the template and instead recompile it on any
 // call (to make changes to a template live instantaneously).
 func (set *TemplateSet) FromCache(filename string) (*Template, error) {
+	tpl, err := set.fromCache(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.checkRequiredBlocks(); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// fromCache works like FromCache, but doesn't check the template's required
+// blocks (e. g. for parent templates).
+func (set *TemplateSet) fromCache(filename string) (*Template, error) {
 	if set.Debug {
 		// Recompile on any request
-		return set.FromFile(filename)
+		return set.fromFile(filename)
 	}
 	// Cache the template
 	cleanedFilename := set.resolveFilename(nil, filename)
 
+	set.templateCacheMutex.Lock()
+	tpl, has := set.templateCache[cleanedFilename]
+	revalidate := has && set.revalidationDue(set.templateChecked, cleanedFilename)
+	set.templateCacheMutex.Unlock()
+
+	// Cache hit
+	var outdatedTpl *Template
+	if has {
+		if !revalidate || !tpl.outdated() {
+			return tpl, nil
+		}
+		outdatedTpl = tpl
+	}
+
+	// Cache miss (or outdated template); the mutex must not be held while compiling
+	// as the template might import other templates through the cache.
+	tpl, err := set.fromFile(cleanedFilename)
+	if err != nil {
+		return nil, err
+	}
+
 	set.templateCacheMutex.Lock()
 	defer set.templateCacheMutex.Unlock()
+	if cachedTpl, has := set.templateCache[cleanedFilename]; has && cachedTpl != outdatedTpl {
+		// Compiled concurrently, keep the first one
+		return cachedTpl, nil
+	}
+	set.templateCache[cleanedFilename] = tpl
+	set.templateChecked[cleanedFilename] = time.Now()
+	return tpl, nil
+}
 
-	tpl, has := set.templateCache[cleanedFilename]
+// revalidationDue reports whether the cache entry key must be revalidated and
+// records the check in checked if so. The caller must hold templateCacheMutex.
+func (set *TemplateSet) revalidationDue(checked map[string]time.Time, key string) bool {
+	if !set.RevalidateCache {
+		return false
+	}
+	interval := set.RevalidateInterval
+	if interval == 0 {
+		interval = DefaultRevalidateInterval
+	}
+	now := time.Now()
+	if now.Sub(checked[key]) < interval {
+		return false
+	}
+	checked[key] = now
+	return true
+}
+
+// templateSource is the origin of a template compiled from a versioned loader.
+type templateSource struct {
+	loader  TemplateVersioner
+	path    string
+	version string // the version the template has been compiled from
+}
+
+// outdated reports whether the template or any of its dependencies has been changed
+// (or removed) since it has been compiled according to the versions reported by
+// the loaders.
+func (tpl *Template) outdated() bool {
+	if tpl.source != nil {
+		version, err := tpl.source.loader.Version(tpl.source.path)
+		if err != nil || version != tpl.source.version {
+			return true
+		}
+	}
+	for _, dependency := range tpl.dependencies {
+		if dependency.outdated() {
+			return true
+		}
+	}
+	return false
+}
 
-	// Cache miss
-	if !has {
-		tpl, err := set.FromFile(cleanedFilename)
+// fromCacheFirstFound compiles (through the cache) the first of the given templates
+// the set's loaders can find; filenames are resolved relative to tpl. Which one
+// has been chosen is cached as well (unless in debug mode) and chosen again from time
+// to time if RevalidateCache is enabled. found is false if none
+// of the templates exists. Required blocks aren't checked (see fromCache).
+func (set *TemplateSet) fromCacheFirstFound(tpl *Template, filenames []string) (chosen *Template, found bool, err error) {
+	resolved := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		// Normalized like fromCache does (string templates don't resolve names), so a
+		// TemplateNotFoundError of fromCache names the candidate exactly
+		resolved = append(resolved, set.resolveFilename(nil, set.resolveFilename(tpl, filename)))
+	}
+	key := strings.Join(resolved, "\x00")
+
+	if !set.Debug {
+		set.templateCacheMutex.Lock()
+		filename, has := set.firstFoundCache[key]
+		if has && set.revalidationDue(set.firstFoundChecked, key) {
+			// A template in front of the chosen one might have been added
+			has = false
+		}
+		set.templateCacheMutex.Unlock()
+		if has {
+			chosen, err = set.fromCache(filename)
+			if err == nil || !isTemplateNotFound(err, filename) {
+				return chosen, true, err
+			}
+			// Removed in the meantime, choose again
+		}
+	}
+
+	for _, filename := range resolved {
+		chosen, err = set.fromCache(filename)
 		if err != nil {
-			return nil, err
+			if isTemplateNotFound(err, filename) {
+				continue
+			}
+			return nil, true, err
+		}
+
+		if !set.Debug {
+			set.templateCacheMutex.Lock()
+			set.firstFoundCache[key] = filename
+			set.firstFoundChecked[key] = time.Now()
+			set.templateCacheMutex.Unlock()
 		}
-		set.templateCache[cleanedFilename] = tpl
-		return tpl, nil
+		return chosen, true, nil
 	}
 
-	// Cache hit
-	return tpl, nil
+	return nil, false, nil
 }
 
 // FromString loads a template from string and returns a Template instance.
@@ -208,9 +496,22 @@ func (set *TemplateSet) FromBytes(tpl []byte) (*Template, error) {
 
 // FromFile loads a template from a filename and returns a Template instance.
 func (set *TemplateSet) FromFile(filename string) (*Template, error) {
+	tpl, err := set.fromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.checkRequiredBlocks(); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// fromFile works like FromFile, but doesn't check the template's required
+// blocks (e. g. for parent templates).
+func (set *TemplateSet) fromFile(filename string) (*Template, error) {
 	set.firstTemplateCreated = true
 
-	_, _, fd, err := set.resolveTemplate(nil, filename)
+	path, loader, fd, err := set.resolveTemplate(nil, filename)
 	if err != nil {
 		return nil, &Error{
 			Filename:  filename,
@@ -218,6 +519,14 @@ func (set *TemplateSet) FromFile(filename string) (*Template, error) {
 			OrigError: err,
 		}
 	}
+
+	// The version is determined before reading the template; a change in between
+	// results in an (unnecessary) recompilation only.
+	var version string
+	if versioner, ok := loader.(TemplateVersioner); ok {
+		version, _ = versioner.Version(path)
+	}
+
 	buf, err := io.ReadAll(fd)
 	if err != nil {
 		return nil, &Error{
@@ -227,7 +536,14 @@ func (set *TemplateSet) FromFile(filename string) (*Template, error) {
 		}
 	}
 
-	return newTemplate(set, filename, false, buf)
+	tpl, err := newTemplate(set, filename, false, buf)
+	if err != nil {
+		return nil, err
+	}
+	if version != "" {
+		tpl.source = &templateSource{loader: loader.(TemplateVersioner), path: path, version: version}
+	}
+	return tpl, nil
 }
 
 // RenderTemplateString is a shortcut and renders a template string directly.
@@ -0,0 +1,11 @@
+{% macro card(title, class="card") %}<div class="{{ class }}"><h2>{{ title }}</h2>{{ caller() }}</div>{% endmacro %}
+{% call card("Hello") %}<p>Body of {{ simple.name }}</p>{% endcall %}
+{% call card(simple.name|capfirst, class="wide") %}{{ simple.xss }}{% endcall %}
+{% macro list(items) %}<ul>{% for item in items %}<li>{{ caller(item, forloop.Counter) }}</li>{% endfor %}</ul>{% endmacro %}
+{% call(item, idx) list(simple.misc_list) %}{{ idx }}: {{ item }}{% endcall %}
+{% macro each(items) %}{% for item in items %}{{ caller(item) }}{% endfor %}{% endmacro %}{% call(item, suffix="!") each(simple.fixed_item_list) %}{{ item }}{{ suffix }}{% endcall %}
+{% macro outer() %}[{% call card("inner") %}{{ caller() }}{% endcall %}]{% endmacro %}
+{% call outer() %}nested{% endcall %}
+{% for i in simple.fixed_item_list %}{% call card(i) %}{{ i * 2 }}{% endcall %}{% endfor %}
+{% from "macro.helper" import * %}{% call imported_macro("User1") %}ignored{% endcall %}
+{% macro each_kw(items) %}{% for item in items %}{{ caller(item, suffix="?") }}{% endfor %}{% endmacro %}{% call(item, suffix="!") each_kw(simple.fixed_item_list) %}{{ item }}{{ suffix }}{% endcall %}
@@ -0,0 +1,9 @@
+{% component "component_button.helper" variant="primary" %}{% slot icon %}<i class="{{ simple.name|lower }}"></i>{% endslot %}Click {{ simple.name }}{% endcomponent %}
+{% component "component_button.helper" %}{% endcomponent %}
+{% component "component_button.helper" %}
+    {% slot default %}Default {{ simple.number }}{% endslot %}
+{% endcomponent %}
+{% component "component_button.helper" variant=simple.name %}{% slot icon %}{% endslot %}{% endcomponent %}
+{% for i in simple.fixed_item_list %}{% component "component_button.helper" %}{% slot icon %}{{ i }}{% endslot %}{% if forloop.Last %}last{% endif %}{% endcomponent %}{% endfor %}
+{% component "component_card.helper" title="Card" %}{% endcomponent %}
+{% component "component_card.helper" title="Card" %}{% slot header %}<h1>{{ simple.name }}</h1>{% endslot %}{% slot button_icon %}*{% endslot %}{% endcomponent %}
@@ -0,0 +1,9 @@
+{% embed "embed_card.helper" %}{% endembed %}
+{% embed "embed_card.helper" with title="Hello" %}
+    {% block text %}Text of {{ simple.name }}{% endblock %}
+{% endembed %}
+{% embed "embed_card.helper" with title=simple.name only %}{% block header %}{{ block.Super }}<small>{{ title|upper }}</small>{% endblock %}{% endembed %}
+{% embed "embed_card.helper" %}{% block body %}[{{ block.Super }}]{% endblock %}{% block text %}overridden{% endblock %}{% endembed %}
+{% for i in simple.fixed_item_list %}{% embed "embed_card.helper" with title=i %}{% block text %}{{ i * 2 }}{% endblock %}{% endembed %}{% endfor %}
+{% block text %}Outer block{% endblock %}
+{% embed "extends_super.tpl" %}{% block content %}{{ block.Super }}+embedded{% endblock %}{% endembed %}
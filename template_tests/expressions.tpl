@@ -71,4 +71,23 @@ issue #64 (uint comparison with int const)
 string concatenation
 {{ "a" + "b" }}
 {{ 1 + "a" }}
-{{ "a" + "1" }}
\ No newline at end of file
+{{ "a" + "1" }}
+
+conditional expressions
+{{ "yes" if simple.bool_true else "no" }}
+{{ "yes" if simple.bool_false else "no" }}
+{{ "yes" if simple.bool_false }}
+{{ "big" if simple.number > 40 else "medium" if simple.number > 20 else "small" }}
+{{ "small" if simple.number < 20 or simple.number > 100 else "in range" }}
+{{ 1 + 2 if simple.bool_true and simple.nil == nil else 3 }}
+{{ simple.name|upper if simple.name else "nobody" }}
+{{ simple.xss if simple.bool_true else "" }}
+{{ simple.xss|safe if simple.bool_true else "" }}
+{{ simple.xss|safe if simple.bool_true else simple.xss }}
+{% if "a" if simple.bool_false else "" %}no{% else %}yes{% endif %}
+
+map literals
+{% with m={"name": simple.name, "nested": {"number": simple.number, "xss": simple.xss}, 1: "one", "list": [1, 2]} %}{{ m.name }} {{ m.nested.number }} {{ m["nested"]["number"] }} {{ m.nested.xss }} {{ m["1"] }} {{ m.list|length }}
+{{ "name" in m }} {{ "missing" in m }} {{ m|length }} {{ {}|length }} {{ {"a": 1}|length }}
+{% for key, value in m sorted %}{% if key != "nested" and key != "list" %}{{ key }}={{ value }} {% endif %}{% endfor %}{% endwith %}
+{{ {"a": "yes"} if false else {"b": "no", "c": 1}|length }}
\ No newline at end of file
@@ -0,0 +1,3 @@
+{% extends ["inheritance/not_existing.tpl", "inheritance/base.tpl"] %}
+
+{% block content %}Dynamic content ({{ block.Super }}){% endblock %}
\ No newline at end of file
@@ -320,3 +320,11 @@ truncatewords_html
 {{ "<p>This </a>is a long test, which will be cutted after some words.</p>"|truncatewords_html:5 }}
 {{ "<p>This is a long test which will be cutted after some words.</p>"|truncatewords_html:2 }}
 {{ "<p>This is a long test which will be cutted after some words.</p>"|truncatewords_html:0 }}
+
+{{ "This is a long test which will be truncated."|truncate(14) }}
+{{ "This is a long test which will be truncated."|truncate(14, end="…") }}
+{{ "This is a long test which will be truncated."|truncate(end="!", length=5) }}
+{{ "This is a long test which will be truncated."|truncate:7 }}
+{{ "This is short."|truncate(20) }}
+{{ "This is a long test which will be truncated."|truncate }}
+{{ simple.name|truncate(simple.number - 40, end=simple.name|upper|slice:":1") }}
\ No newline at end of file
@@ -42,4 +42,46 @@ string unicode
 '{% for char in simple.chinese_hello_world %}{{ char }}{% endfor %}'
 
 string unicode sorted reversed
-'{% for char in simple.chinese_hello_world reversed sorted %}{{ char }}{% endfor %}'
\ No newline at end of file
+'{% for char in simple.chinese_hello_world reversed sorted %}{{ char }}{% endfor %}'
+
+break
+'{% for item in simple.multiple_item_list %}{% if item > 5 %}{% break %}{% endif %}{{ item }} {% endfor %}'
+
+continue
+'{% for item in simple.multiple_item_list %}{% if item is odd %}{% continue %}{% endif %}{{ item }} {% endfor %}'
+
+first three matching items
+'{% for item in simple.multiple_item_list %}{% if forloop.Counter > 3 %}{% break %}{% endif %}{% with doubled=item*2 %}{% if doubled > 10 %}{% continue %}{% endif %}{{ doubled }} {% endwith %}{% endfor %}'
+
+nested loops
+'{% for outer in simple.fixed_item_list %}{% for inner in simple.fixed_item_list %}{% if inner == outer %}{% break %}{% endif %}{{ outer }}{{ inner }} {% endfor %}{% if outer == 3 %}{% break %}{% endif %}{% endfor %}'
+
+empty with break
+'{% for item in simple.one_item_list %}{% break %}{{ item }}{% empty %}empty{% endfor %}'
+
+break/continue within buffering tags
+'{% for item in simple.fixed_item_list %}{% filter upper %}a{{ item }}{% if item == 2 %}{% break %}{% endif %}b{% endfilter %} {% endfor %}'
+'{% for item in simple.fixed_item_list %}{% spaceless %}<i> {{ item }}</i> {% if item is odd %}{% continue %}{% endif %}<b></b>{% endspaceless %}|{% endfor %}'
+'{% set ns = namespace(x="") %}{% for item in simple.fixed_item_list %}{% set ns.x %}{{ ns.x }}<{{ item }}{% if item == 2 %}{% break %}{% endif %}>{% endset %}{% endfor %}{{ ns.x }}'
+
+filtered loop
+'{% for item in simple.multiple_item_list if item is odd %}{{ forloop.Counter }}/{{ forloop.Length }}:{{ item }}{% if forloop.Last %}!{% endif %} {% endfor %}'
+'{% for item in simple.multiple_item_list if item > 100 %}{{ item }}{% empty %}nothing{% endfor %}'
+'{% for key, value in simple.strmap if key != "abc" and key != "gh" sorted %}{{ key }}={{ value }} {% endfor %}'
+'{% for item in simple.multiple_item_list if item is even reversed %}{{ item }} {% endfor %}'
+
+loop length and cycle
+'{% for item in simple.fixed_item_list %}{{ forloop.Counter }}/{{ forloop.Length }}={{ forloop.Cycle("odd", "even") }} {% endfor %}'
+'{% for item in simple.misc_list %}{{ forloop.Cycle("a", "b", "c") }}{% endfor %}'
+
+previous and next item
+'{% for item in simple.fixed_item_list %}({{ forloop.Previtem|default:"-" }}<{{ item }}<{{ forloop.Nextitem|default:"-" }}) {% endfor %}'
+'{% for item in simple.multiple_item_list %}{% if item != forloop.Previtem %}{{ item }} {% endif %}{% endfor %}'
+'{% for key, value in simple.strmap sorted %}{{ key }}({{ forloop.Previtem|default:"-" }},{{ forloop.Nextitem|default:"-" }}) {% endfor %}'
+'{% for key in simple.strmap sorted %}{{ key }}({{ forloop.Previtem|default:"-" }},{{ forloop.Nextitem|default:"-" }}) {% endfor %}'
+
+unpacking
+'{% for idx, item in simple.misc_list|enumerate %}{{ idx }}={{ item }} {% endfor %}'
+'{% for idx, item in simple.fixed_item_list|enumerate:1 if item is even %}{{ idx }}={{ item }} {% endfor %}'
+'{% for name, score in [["john", 3], ["jane", 5]] %}{{ name }}:{{ score }} {% endfor %}'
+'{% for a, b, c in [[1, 2, 3], [4, 5, 6]] reversed %}{{ a + b + c }} {% endfor %}'
\ No newline at end of file
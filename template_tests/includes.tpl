@@ -4,4 +4,7 @@ Start '{% include "includes.helper" with what_am_i=simple.name only %}' End
 Start '{% include "includes.helper" with what_am_i=simple.name %}' End
 Start '{% include simple.included_file|lower with number=7 what_am_i="guest" %}' End
 Start '{% include "includes.helper.not_exists" if_exists %}' End
-Start '{% include simple.included_file_not_exists if_exists with number=7 what_am_i="guest" %}' End
\ No newline at end of file
+Start '{% include simple.included_file_not_exists if_exists with number=7 what_am_i="guest" %}' End
+Start '{% include ["includes.helper.not_exists", "includes.helper"] with what_am_i="fallback" %}' End
+Start '{% include [simple.included_file_not_exists, simple.included_file|lower] with number=8 %}' End
+Start '{% include ["includes.helper.not_exists", "includes.helper.not_exists2"] if_exists %}' End
\ No newline at end of file
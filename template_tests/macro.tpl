@@ -19,11 +19,23 @@ issue #39 (deactivate auto-escape of macros)
 {{ html_test("Max") }}
 
 Importing macros
-{% import "macro.helper" imported_macro, imported_macro as renamed_macro, imported_macro as html_test %}
+{% import "macro.helper" imported_macro, imported_macro as renamed_macro, imported_macro as imported_html_test %}
 {{ imported_macro("User1") }}
 {{ renamed_macro("User2") }}
-{{ html_test("Max") }}
+{{ imported_html_test("Max") }}
 
 Chaining macros{% import "macro2.helper" greeter_macro %}
 {{ greeter_macro() }}
+
+Namespace imports{% import "macro.helper" as lib %}{% from "macro2.helper" import greeter_macro as greeter %}
+{{ lib.imported_macro("Namespace") }} {{ lib.imported_macro_void() }}
+{{ greeter() }}
+
+Keyword arguments
+{{ greetings(name2="jane", to="john") }}
+{{ greetings("john", name2="jane") }}
+{% macro input(name, type="text", *varargs, **kwargs) %}<input name="{{ name }}" type="{{ type }}"{% for key, value in kwargs sorted %} {{ key }}="{{ value }}"{% endfor %}>{% for arg in varargs %}[{{ arg }}]{% endfor %}{% endmacro %}
+{{ input("email", "email", 1, 2, placeholder="Your mail", required=true) }}
+{{ input(name="age", min=0) }}
+{{ input("x") }}|{{ input("x", "y", "z") }}
 End
@@ -0,0 +1,5 @@
+{% regroup complex.comments2 by Author.Name as authors %}{% for author in authors %}{{ author.Grouper }}: {% for comment in author.List %}{{ comment.Date|date:"2006-01-02" }}{% if !forloop.Last %}, {% endif %}{% endfor %}
+{% endfor %}{% regroup complex.comments by Author.Validated as states %}{% for validated, comments in states %}{{ validated }} ({{ comments|length }})
+{% endfor %}{% regroup [{"name": "a", "team": "red"}, {"name": "b", "team": "red"}, {"name": "c", "team": "blue"}, {"name": "d", "team": "red"}] by team as teams %}{% for team in teams %}{{ team.Grouper }}={% for p in team.List %}{{ p.name }}{% endfor %} {% endfor %}
+{% regroup [{"a": 1}, {"a": 2}] by missing as groups %}{{ groups.0.Grouper|default:"none" }} {{ groups|length }}
+{% regroup simple.nil by name as groups %}{{ groups|length }}
@@ -28,3 +28,13 @@
 
 {% set nil_set = nil %}{% for var in nil_set %}-{{ var }} {# printing an additional dash here to show that the nil array won't be looped  #}
 {% endfor %}
+
+{% set greeting %}Hello {{ simple.name|capfirst }}, {{ simple.xss }}!{% endset %}{{ greeting }}
+{% set greeting_upper %}{% filter upper %}{{ greeting }}{% endfilter %}{% endset %}{{ greeting_upper }}
+{% autoescape off %}{% set raw %}{{ simple.xss }}{% endset %}{% endautoescape %}{{ raw }}
+{% autoescape off %}{% set raw %}{{ simple.xss }}{% endset %}{{ raw }}{% endautoescape %}
+{% set total %}{% for item in simple.fixed_item_list %}{{ item }}{% endfor %}{% endset %}{{ total|length }}
+
+{% set ns = namespace(total=0, found=false, names=[]) %}{% for item in simple.multiple_item_list %}{% set ns.total = ns.total + item %}{% if item == 13 %}{% set ns.found = true %}{% endif %}{% endfor %}{{ ns.total }} {{ ns.found }}
+{% set counter = namespace() %}{% set counter.value = 1 %}{% for item in simple.fixed_item_list %}{% with double=item*2 %}{% set counter.value = counter.value * double %}{% endwith %}{% endfor %}{{ counter.value }}
+{% set ns.last %}{% for item in simple.misc_list %}{% if forloop.Last %}{{ item }}{% endif %}{% endfor %}{% endset %}{{ ns.last }}
@@ -0,0 +1,13 @@
+{% for item in simple.misc_list %}{% switch item %}
+    {% case "Hello" %}greeting
+    {% case 99 3.14 %}number
+    {% default %}other: {{ item }}
+{% endswitch %}{% endfor %}
+{% switch simple.number %}{% case 1, 2, 3 %}small{% case simple.number %}self{% endswitch %}
+{% switch simple.name %}{% case "jane doe" %}jane{% endswitch %}
+{% switch simple.uint %}{% case 8 %}uint matches int{% default %}no match{% endswitch %}
+{% switch simple.nil %}{% case "" %}empty string{% default %}nil{% endswitch %}
+{% for code in simple.multiple_item_list %}{% switch code %}{% case 1 %}{% if forloop.First %}first one{% else %}another one{% endif %}, {% case 13 21 %}{% break %}{% endswitch %}{% endfor %}
+{% switch -1 %}{% case 1 -1 %}one or minus one{% endswitch %}
+{% switch simple.number %}{% case 1 +2 (simple.number * 1) %}computed{% endswitch %}
+{% switch simple.name|lower %}{% case "john"|capfirst "john doe" %}filtered{% endswitch %}
\ No newline at end of file
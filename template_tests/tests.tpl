@@ -0,0 +1,28 @@
+defined/undefined/none
+{{ simple.name is defined }} {{ simple.nil is defined }} {{ simple.missing is defined }} {{ missing.deeply.nested is defined }}
+{{ simple.name is undefined }} {{ simple.missing is undefined }} {{ simple.missing is not defined }}
+{{ simple.nil is none }} {{ simple.missing is none }} {{ simple.name is none }} {{ simple.name is not none }}
+{% if simple.missing is defined %}defined{% else %}undefined{% endif %}
+{% if not simple.missing is defined %}undefined{% else %}defined{% endif %} {% if !simple.name is defined %}undefined{% else %}defined{% endif %} {{ not simple.missing is not defined }} {{ !simple.number is odd }}
+
+types
+{{ simple.bool_true is boolean }} {{ simple.number is boolean }}
+{{ simple.number is number }} {{ simple.float is number }} {{ simple.name is number }}
+{{ simple.number is integer }} {{ simple.float is integer }} {{ simple.float is float }}
+{{ simple.name is string }} {{ simple.number is string }}
+{{ simple.multiple_item_list is iterable }} {{ simple.strmap is iterable }} {{ simple.name is iterable }} {{ simple.number is iterable }}
+{{ simple.multiple_item_list is sequence }} {{ simple.strmap is sequence }}
+{{ simple.strmap is mapping }} {{ simple.multiple_item_list is mapping }} {{ {"a": 1} is mapping }}
+
+values
+{{ simple.number is divisibleby 3 }} {{ simple.number is divisibleby(7) }} {{ simple.number is divisibleby(simple.uint) }} {{ simple.number is not divisibleby 5 }} {{ simple.number is divisibleby 0 }}
+{{ simple.number is even }} {{ simple.number is odd }} {{ simple.uint + 1 is odd }}
+{{ simple.name is lower }} {{ simple.name is upper }} {{ simple.name|upper is upper }}
+{{ simple.number is eq 42 }} {{ simple.number is eq(simple.number) }} {{ simple.number is not eq "42" }}
+{{ simple.number is in [1, 42] }} {{ "abc" is in(simple.strmap) }} {{ "xyz" is not in(simple.strmap) }}
+
+combined
+{{ simple.missing is defined and simple.missing > 1 }}
+{{ simple.name is string and simple.name is not upper }}
+{{ "number" if simple.number is number else "no number" }}
+{% for item in simple.misc_list %}{% if item is number %}{{ item }} {% endif %}{% endfor %}
\ No newline at end of file
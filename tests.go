@@ -0,0 +1,49 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// TestFunction is the type test functions must fulfil. Tests are used with the
+// is-operator within expressions and return whether the given value passes the
+// test, e. g.:
+//
+//	{% if value is defined %}
+//	{% if number is divisibleby 3 %}
+//	{% if number is not divisibleby(3) %}
+//
+// param is a nil-*Value if the test is used without an argument.
+type TestFunction func(in *Value, param *Value) (bool, *Error)
+
+var tests map[string]TestFunction
+
+func init() {
+	tests = make(map[string]TestFunction)
+}
+
+// TestExists returns true if the given test is already registered
+func TestExists(name string) bool {
+	_, existing := tests[name]
+	return existing
+}
+
+// RegisterTest registers a new test. If there's already a test with the same
+// name, RegisterTest will return an error. You usually want to call this
+// function in the test's init() function.
+func RegisterTest(name string, fn TestFunction) error {
+	if TestExists(name) {
+		return fmt.Errorf("test with name '%s' is already registered", name)
+	}
+	tests[name] = fn
+	return nil
+}
+
+// ReplaceTest replaces an already registered test with a new implementation. Use this
+// function with caution since it allows you to change existing test behaviour.
+func ReplaceTest(name string, fn TestFunction) error {
+	if !TestExists(name) {
+		return fmt.Errorf("test with name '%s' does not exist (therefore cannot be overridden)", name)
+	}
+	tests[name] = fn
+	return nil
+}
@@ -0,0 +1,102 @@
+package pongo2
+
+import (
+	"reflect"
+	"strings"
+)
+
+func init() {
+	RegisterTest("defined", testDefined)
+	RegisterTest("undefined", testUndefined)
+	RegisterTest("none", testNone)
+	RegisterTest("boolean", testBoolean)
+	RegisterTest("number", testNumber)
+	RegisterTest("integer", testInteger)
+	RegisterTest("float", testFloat)
+	RegisterTest("string", testString)
+	RegisterTest("iterable", testIterable)
+	RegisterTest("sequence", testSequence)
+	RegisterTest("mapping", testMapping)
+	RegisterTest("divisibleby", testDivisibleby)
+	RegisterTest("even", testEven)
+	RegisterTest("odd", testOdd)
+	RegisterTest("lower", testLower)
+	RegisterTest("upper", testUpper)
+	RegisterTest("eq", testEq)
+	RegisterTest("in", testIn)
+}
+
+func testDefined(in *Value, param *Value) (bool, *Error) {
+	return !in.IsUndefined(), nil
+}
+
+func testUndefined(in *Value, param *Value) (bool, *Error) {
+	return in.IsUndefined(), nil
+}
+
+func testNone(in *Value, param *Value) (bool, *Error) {
+	return in.IsNil() && !in.IsUndefined(), nil
+}
+
+func testBoolean(in *Value, param *Value) (bool, *Error) {
+	return in.IsBool(), nil
+}
+
+func testNumber(in *Value, param *Value) (bool, *Error) {
+	return in.IsNumber(), nil
+}
+
+func testInteger(in *Value, param *Value) (bool, *Error) {
+	return in.IsInteger(), nil
+}
+
+func testFloat(in *Value, param *Value) (bool, *Error) {
+	return in.IsFloat(), nil
+}
+
+func testString(in *Value, param *Value) (bool, *Error) {
+	return in.IsString(), nil
+}
+
+func testIterable(in *Value, param *Value) (bool, *Error) {
+	return in.CanSlice() || in.getResolvedValue().Kind() == reflect.Map, nil
+}
+
+func testSequence(in *Value, param *Value) (bool, *Error) {
+	return in.CanSlice(), nil
+}
+
+func testMapping(in *Value, param *Value) (bool, *Error) {
+	return in.getResolvedValue().Kind() == reflect.Map, nil
+}
+
+func testDivisibleby(in *Value, param *Value) (bool, *Error) {
+	if param.Integer() == 0 {
+		return false, nil
+	}
+	return in.Integer()%param.Integer() == 0, nil
+}
+
+func testEven(in *Value, param *Value) (bool, *Error) {
+	return in.IsInteger() && in.Integer()%2 == 0, nil
+}
+
+func testOdd(in *Value, param *Value) (bool, *Error) {
+	return in.IsInteger() && in.Integer()%2 != 0, nil
+}
+
+func testLower(in *Value, param *Value) (bool, *Error) {
+	return in.IsString() && strings.ToLower(in.String()) == in.String(), nil
+}
+
+func testUpper(in *Value, param *Value) (bool, *Error) {
+	return in.IsString() && strings.ToUpper(in.String()) == in.String(), nil
+}
+
+func testEq(in *Value, param *Value) (bool, *Error) {
+	return in.EqualValueTo(param), nil
+}
+
+func testIn(in *Value, param *Value) (bool, *Error) {
+	return param.Contains(in), nil
+}
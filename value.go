@@ -10,8 +10,9 @@ import (
 )
 
 type Value struct {
-	val  reflect.Value
-	safe bool // used to indicate whether a Value needs explicit escaping in the template
+	val       reflect.Value
+	safe      bool // used to indicate whether a Value needs explicit escaping in the template
+	undefined bool // set for undefined variables tested with the is-operator
 }
 
 // AsValue converts any given value to a pongo2.Value
@@ -84,6 +85,14 @@ func (v *Value) IsTime() bool {
 	return ok
 }
 
+// IsUndefined checks whether the value comes from an undefined variable. It
+// can only be true for values passed to test functions (see RegisterTest()),
+// as undefined variables are just NIL everywhere else (unless
+// Options.StrictUndefined is set).
+func (v *Value) IsUndefined() bool {
+	return v.undefined
+}
+
 // IsNil checks whether the underlying value is NIL
 func (v *Value) IsNil() bool {
 	// fmt.Printf("%+v\n", v.getResolvedValue().Type().String())
@@ -360,7 +369,12 @@ func (v *Value) Contains(other *Value) bool {
 	case reflect.Slice, reflect.Array:
 		for i := 0; i < baseValue.Len(); i++ {
 			item := baseValue.Index(i)
-			if other.EqualValueTo(AsValue(item.Interface())) {
+			// Items of in-template array definitions are *Values already
+			itemValue, isValue := item.Interface().(*Value)
+			if !isValue {
+				itemValue = AsValue(item.Interface())
+			}
+			if other.EqualValueTo(itemValue) {
 				return true
 			}
 		}
@@ -1,7 +1,6 @@
 package pongo2
 
 import (
-	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -19,6 +18,10 @@ const (
 var (
 	typeOfValuePtr   = reflect.TypeOf(new(Value))
 	typeOfExecCtxPtr = reflect.TypeOf(new(ExecutionContext))
+
+	// Only macros (and the callers of call blocks) accept keyword arguments
+	typeOfMacroFunction = reflect.TypeOf(macroFunction(nil))
+	typeOfMacroCaller   = reflect.TypeOf(macroCaller(nil))
 )
 
 type variablePart struct {
@@ -29,6 +32,7 @@ type variablePart struct {
 	isNil     bool
 
 	isFunctionCall bool
+	hasKwargs      bool                   // callingArgs contain a *macroKwargsEval
 	callingArgs    []functionCallArgument // needed for a function call, represents all argument nodes (INode supports nested function calls)
 }
 
@@ -72,6 +76,13 @@ type boolResolver struct {
 	val           bool
 }
 
+type mapResolver struct {
+	locationToken *Token
+
+	keys   []IEvaluator
+	values []IEvaluator
+}
+
 type variableResolver struct {
 	locationToken *Token
 
@@ -83,6 +94,10 @@ type nodeFilteredVariable struct {
 
 	resolver    IEvaluator
 	filterChain []*filterCall
+
+	// guardsUndefined is true if the filter chain handles undefined
+	// variables (default, default_if_none), see Options.StrictUndefined
+	guardsUndefined bool
 }
 
 type nodeVariable struct {
@@ -110,6 +125,15 @@ func (vr *variableResolver) Execute(ctx *ExecutionContext, writer TemplateWriter
 	return nil
 }
 
+func (m *mapResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := m.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (s *stringResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := s.Evaluate(ctx)
 	if err != nil {
@@ -154,6 +178,10 @@ func (vr *variableResolver) GetPositionToken() *Token {
 	return vr.locationToken
 }
 
+func (m *mapResolver) GetPositionToken() *Token {
+	return m.locationToken
+}
+
 func (s *stringResolver) GetPositionToken() *Token {
 	return s.locationToken
 }
@@ -170,6 +198,24 @@ func (b *boolResolver) GetPositionToken() *Token {
 	return b.locationToken
 }
 
+// Evaluate builds a map[string]*Value; keys are converted to strings so the map
+// can be accessed using the dot-notation and checked using the in-operator.
+func (m *mapResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	items := make(map[string]*Value, len(m.keys))
+	for idx, keyExpr := range m.keys {
+		key, err := keyExpr.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value, err := m.values[idx].Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items[key.String()] = value
+	}
+	return AsValue(items), nil
+}
+
 func (s *stringResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return AsValue(s.val), nil
 }
@@ -186,6 +232,10 @@ func (b *boolResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return AsValue(b.val), nil
 }
 
+func (m *mapResolver) FilterApplied(name string) bool {
+	return false
+}
+
 func (s *stringResolver) FilterApplied(name string) bool {
 	return false
 }
@@ -214,7 +264,7 @@ func (nv *nodeVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 
 	if !nv.expr.FilterApplied("safe") && !value.safe && value.IsString() && ctx.Autoescape {
 		// apply escape filter
-		value, err = filters["escape"](value, nil)
+		value, err = filters["escape"].fn(ctx, value, nil)
 		if err != nil {
 			return err
 		}
@@ -241,6 +291,26 @@ func (vr *variableResolver) String() string {
 	return strings.Join(parts, ".")
 }
 
+// undefinedVariableError is returned by variableResolver.resolve() if
+// a variable (or one of its parts) can't be resolved.
+type undefinedVariableError struct {
+	variable string // full variable path
+	part     string // the part which could not be resolved
+	reason   string
+}
+
+func (e *undefinedVariableError) Error() string {
+	return fmt.Sprintf("variable '%s' is undefined ('%s': %s)", e.variable, e.part, e.reason)
+}
+
+func (vr *variableResolver) undefined(idx int, reason string) error {
+	return &undefinedVariableError{
+		variable: vr.String(),
+		part:     vr.parts[idx].String(),
+		reason:   reason,
+	}
+}
+
 func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 	var current reflect.Value
 	var isSafe bool
@@ -249,17 +319,12 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 	if len(vr.parts) > 0 && vr.parts[0].typ == varTypeArray {
 		items := make([]*Value, 0)
 		for _, part := range vr.parts {
-			switch v := part.subscript.(type) {
-			case *nodeFilteredVariable:
-				item, err := v.resolver.Evaluate(ctx)
-				if err != nil {
-					return nil, err
-				}
-
-				items = append(items, item)
-			default:
-				return nil, errors.New("unknown variable type is given")
+			item, err := part.subscript.Evaluate(ctx)
+			if err != nil {
+				return nil, err
 			}
+
+			items = append(items, item)
 		}
 
 		return &Value{
@@ -276,7 +341,11 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 			val, inPrivate := ctx.Private[vr.parts[0].s]
 			if !inPrivate {
 				// Nothing found? Then have a final lookup in the public context
-				val = ctx.Public[vr.parts[0].s]
+				var inPublic bool
+				val, inPublic = ctx.Public[vr.parts[0].s]
+				if !inPublic {
+					return AsValue(nil), vr.undefined(idx, "unknown name")
+				}
 			}
 			current = reflect.ValueOf(val) // Get the initial value
 		} else {
@@ -314,7 +383,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 							current = current.Index(part.i)
 						} else {
 							// In Django, exceeding the length of a list is just empty.
-							return AsValue(nil), nil
+							return AsValue(nil), vr.undefined(idx, "index out of range")
 						}
 					default:
 						return nil, fmt.Errorf("can't access an index on type %s (variable %s)",
@@ -325,8 +394,14 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 					switch current.Kind() {
 					case reflect.Struct:
 						current = current.FieldByName(part.s)
+						if !current.IsValid() {
+							return AsValue(nil), vr.undefined(idx, "no such field")
+						}
 					case reflect.Map:
 						current = current.MapIndex(reflect.ValueOf(part.s))
+						if !current.IsValid() {
+							return AsValue(nil), vr.undefined(idx, "no such key")
+						}
 					default:
 						return nil, fmt.Errorf("can't access a field by name on type %s (variable %s)",
 							current.Kind().String(), vr.String())
@@ -345,7 +420,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 							current = current.Index(si)
 						} else {
 							// In Django, exceeding the length of a list is just empty.
-							return AsValue(nil), nil
+							return AsValue(nil), vr.undefined(idx, "index out of range")
 						}
 					// Calling a field or key
 					case reflect.Struct:
@@ -354,18 +429,24 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 							return nil, err
 						}
 						current = current.FieldByName(sv.String())
+						if !current.IsValid() {
+							return AsValue(nil), vr.undefined(idx, "no such field")
+						}
 					case reflect.Map:
 						sv, err := part.subscript.Evaluate(ctx)
 						if err != nil {
 							return nil, err
 						}
 						if sv.IsNil() {
-							return AsValue(nil), nil
+							return AsValue(nil), vr.undefined(idx, "no such key")
 						}
 						if sv.val.Type().AssignableTo(current.Type().Key()) {
 							current = current.MapIndex(sv.val)
+							if !current.IsValid() {
+								return AsValue(nil), vr.undefined(idx, "no such key")
+							}
 						} else {
-							return AsValue(nil), nil
+							return AsValue(nil), vr.undefined(idx, "no such key")
 						}
 					default:
 						return nil, fmt.Errorf("can't access an index on type %s (variable %s)",
@@ -384,16 +465,23 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 
 		// If current is a reflect.ValueOf(pongo2.Value), then unpack it
 		// Happens in function calls (as a return value) or by injecting
-		// into the execution context (e.g. in a for-loop)
-		if current.Type() == typeOfValuePtr {
+		// into the execution context (e.g. in a for-loop). Items of list
+		// literals might be wrapped more than once.
+		for current.IsValid() {
+			// Check whether this is an interface and resolve it where required
+			if current.Kind() == reflect.Interface {
+				current = reflect.ValueOf(current.Interface())
+				continue
+			}
+			if current.Type() != typeOfValuePtr {
+				break
+			}
 			tmpValue := current.Interface().(*Value)
 			current = tmpValue.val
 			isSafe = tmpValue.safe
 		}
-
-		// Check whether this is an interface and resolve it where required
-		if current.Kind() == reflect.Interface {
-			current = reflect.ValueOf(current.Interface())
+		if !current.IsValid() {
+			return AsValue(nil), nil
 		}
 
 		// Check if the part is a function call
@@ -413,6 +501,11 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 				currArgs = append([]functionCallArgument{executionCtxEval{}}, currArgs...)
 			}
 
+			// Keyword arguments are passed as a single *Value (which macros unpack)
+			if part.hasKwargs && t != typeOfMacroFunction && t != typeOfMacroCaller {
+				return nil, fmt.Errorf("'%s' does not accept keyword arguments", vr.String())
+			}
+
 			// Input arguments
 			if len(currArgs) != t.NumIn() && !(len(currArgs) >= t.NumIn()-1 && t.IsVariadic()) {
 				return nil,
@@ -519,7 +612,15 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 func (vr *variableResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	value, err := vr.resolve(ctx)
 	if err != nil {
-		return AsValue(nil), ctx.Error(err.Error(), vr.locationToken)
+		if _, isUndefined := err.(*undefinedVariableError); isUndefined && !ctx.undefinedIsError() {
+			// Unresolvable variables are just empty (see Options.StrictUndefined)
+			return AsValue(nil), nil
+		}
+		if e, isError := err.(*Error); isError {
+			// Errors of called macros (or recursive loops) are located already
+			return AsValue(nil), e
+		}
+		return AsValue(nil), ctx.OrigError(err, vr.locationToken)
 	}
 	return value, nil
 }
@@ -534,7 +635,11 @@ func (v *nodeFilteredVariable) FilterApplied(name string) bool {
 }
 
 func (v *nodeFilteredVariable) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
-	value, err := v.resolver.Evaluate(ctx)
+	resolverCtx := ctx
+	if v.guardsUndefined {
+		resolverCtx = ctx.allowUndefined()
+	}
+	value, err := v.resolver.Evaluate(resolverCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -592,7 +697,53 @@ func (p *Parser) parseArray() (IEvaluator, *Error) {
 	return resolver, nil
 }
 
-// IDENT | IDENT.(IDENT|NUMBER)... | IDENT[expr]... | "[" [ expr {, expr}] "]"
+// "{" [expr ":" expr {, expr ":" expr}] "}"
+func (p *Parser) parseMap() (IEvaluator, *Error) {
+	resolver := &mapResolver{
+		locationToken: p.Current(),
+	}
+	p.Consume() // We consume '{'
+
+	// We allow an empty map, so check for a closing brace.
+	if p.Match(TokenSymbol, "}") != nil {
+		return resolver, nil
+	}
+
+	for {
+		if p.Remaining() == 0 {
+			return nil, p.Error("Unexpected EOF, unclosed map literal.", p.lastToken)
+		}
+
+		keyExpr, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.Match(TokenSymbol, ":") == nil {
+			return nil, p.Error("Missing ':' after map key.", p.Current())
+		}
+		valueExpr, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		resolver.keys = append(resolver.keys, keyExpr)
+		resolver.values = append(resolver.values, valueExpr)
+
+		if p.Match(TokenSymbol, "}") != nil {
+			// If there's a closing brace after an entry, we will stop parsing the entries
+			break
+		}
+
+		// If there's NO closing brace, there MUST be an comma
+		if p.Match(TokenSymbol, ",") == nil {
+			return nil, p.Error("Missing comma or closing brace after map entry.", p.Current())
+		}
+	}
+
+	return resolver, nil
+}
+
+// IDENT | IDENT.(IDENT|NUMBER)... | IDENT[expr]... | "[" [ expr {, expr}] "]" | "{" [expr ":" expr {, expr ":" expr}] "}"
 func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 	t := p.Current()
 
@@ -665,6 +816,10 @@ func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 			// Parsing an array literal [expr {, expr}]
 			return p.parseArray()
 		}
+		if t.Val == "{" {
+			// Parsing a map literal {expr: expr {, expr: expr}}
+			return p.parseMap()
+		}
 	}
 
 	resolver := &variableResolver{
@@ -739,12 +894,14 @@ variableLoop:
 			if p.Match(TokenSymbol, "]") == nil {
 				return nil, p.Error("Missing closing bracket after subscript argument.", nil)
 			}
-
+			// Subscripts can be chained (e. g. m["a"]["b"])
+			continue variableLoop
 		} else if p.Match(TokenSymbol, "(") != nil {
 			// Function call
 			// FunctionName '(' Comma-separated list of expressions ')'
 			part := resolver.parts[len(resolver.parts)-1]
 			part.isFunctionCall = true
+			var kwargs *macroKwargsEval
 		argumentLoop:
 			for {
 				if p.Remaining() == 0 {
@@ -753,11 +910,34 @@ variableLoop:
 
 				if p.Peek(TokenSymbol, ")") == nil {
 					// No closing bracket, so we're parsing an expression
-					exprArg, err := p.ParseExpression()
-					if err != nil {
-						return nil, err
+					if p.PeekType(TokenIdentifier) != nil && p.PeekN(1, TokenSymbol, "=") != nil {
+						// Keyword argument (supported by macros only)
+						nameToken := p.MatchType(TokenIdentifier)
+						p.Consume() // '='
+						if kwargs == nil {
+							kwargs = &macroKwargsEval{}
+						}
+						for _, name := range kwargs.names {
+							if name == nameToken.Val {
+								return nil, p.Error(fmt.Sprintf("Keyword argument '%s' repeated.", name), nameToken)
+							}
+						}
+						exprArg, err := p.ParseExpression()
+						if err != nil {
+							return nil, err
+						}
+						kwargs.names = append(kwargs.names, nameToken.Val)
+						kwargs.exprs = append(kwargs.exprs, exprArg)
+					} else {
+						if kwargs != nil {
+							return nil, p.Error("Positional argument follows keyword argument.", nil)
+						}
+						exprArg, err := p.ParseExpression()
+						if err != nil {
+							return nil, err
+						}
+						part.callingArgs = append(part.callingArgs, exprArg)
 					}
-					part.callingArgs = append(part.callingArgs, exprArg)
 
 					if p.Match(TokenSymbol, ")") != nil {
 						// If there's a closing bracket after an expression, we will stop parsing the arguments
@@ -775,6 +955,10 @@ variableLoop:
 				}
 
 			}
+			if kwargs != nil {
+				part.callingArgs = append(part.callingArgs, kwargs)
+				part.hasKwargs = true
+			}
 			// We're done parsing the function call, next variable part
 			continue variableLoop
 		}
@@ -813,6 +997,9 @@ filterLoop:
 		}
 
 		v.filterChain = append(v.filterChain, filter)
+		if filter.name == "default" || filter.name == "default_if_none" {
+			v.guardsUndefined = true
+		}
 
 		continue filterLoop
 	}